@@ -1,35 +1,90 @@
 package book
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
 
 // Model an ebook
 type Book struct {
-	Title        string
-	Identifier   string
-	Creators     []string
-	Publisher    string
-	Language     string
-	Subjects     []string
-	Rights       string
-	Source       string
-	Dates        []Date
-	Metas        []Meta
-	Resources    []Resource
-	SpineItems   []SpineItem
-	CoverImageID string
-	TOCItems     []TOCItem
+	Metadata
+	Resources     []Resource
+	SpineItems    []SpineItem
+	CoverImageID  string
+	TOCItems      []TOCItem
+	GuideItems    []GuideItem
+	PageTargets   []PageTarget
+	NavLists      []NavList
+	Rendition     Rendition
+	MediaOverlays MediaOverlays
+}
+
+// Layouts, as used by the rendition:layout property
+const (
+	LayoutReflowable   = "reflowable"
+	LayoutPrePaginated = "pre-paginated"
+)
+
+// How reading systems should present the book, from the EPUB 3 rendition
+// properties. Empty fields are left to the reading system.
+type Rendition struct {
+	Layout      string // One of the Layout* constants
+	Orientation string // auto, landscape or portrait
+	Spread      string // auto, none, landscape or both
+	// Direction pages are turned in, ltr or rtl, from the spine's
+	// page-progression-direction
+	PageProgression string
+}
+
+// Whether pages are laid out at a fixed size, like comics and picture books
+func (b Book) FixedLayout() bool {
+	return b.Rendition.Layout == LayoutPrePaginated
+}
+
+// Bibliographic metadata, which is also the JSON form used for metadata
+// sidecar files
+type Metadata struct {
+	Titles      []Title      `json:"titles,omitempty"`
+	Identifier  string       `json:"identifier,omitempty"`  // The unique identifier
+	Identifiers []Identifier `json:"identifiers,omitempty"` // Any others, e.g. ISBN
+	Creators    []Creator    `json:"creators,omitempty"`
+	Publisher   string       `json:"publisher,omitempty"`
+	Language    string       `json:"language,omitempty"`
+	Subjects    []string     `json:"subjects,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Series      string       `json:"series,omitempty"`
+	SeriesIndex string       `json:"seriesIndex,omitempty"`
+	Rights      string       `json:"rights,omitempty"`
+	Source      string       `json:"source,omitempty"`
+	Dates       []Date       `json:"dates,omitempty"`
+	Metas       []Meta       `json:"metas,omitempty"`
 }
 
 type Resource struct {
-	ID        string
-	Path      string
-	MediaType string
-	Contents  []byte
+	ID           string
+	Path         string
+	MediaType    string
+	Properties   []string // EPUB 3 manifest properties, e.g. svg
+	MediaOverlay string   // ID of the SMIL resource narrating this page
+	Contents     []byte
+}
+
+// Narration synchronised with pages, from the EPUB 3 media overlay
+// properties. The SMIL resources themselves are kept as they are.
+type MediaOverlays struct {
+	Duration            string            // Of the whole book, e.g. 0:32:29.672
+	Durations           map[string]string // By ID of the SMIL resource
+	Narrators           []string
+	ActiveClass         string // Of the element being read out
+	PlaybackActiveClass string // Of the document while narration plays
 }
 
 type SpineItem struct {
-	Linear bool
-	ID     string // Matches some Resource.ID
+	Linear     bool
+	ID         string   // Matches some Resource.ID
+	Properties []string // EPUB 3 itemref properties, e.g. page-spread-left
 }
 
 type TOCItem struct {
@@ -40,14 +95,97 @@ type TOCItem struct {
 	Children  []TOCItem
 }
 
+// Page types, as used by the NCX pageList
+const (
+	PageFront   = "front"
+	PageNormal  = "normal"
+	PageSpecial = "special"
+)
+
+// Where a page of the print edition begins
+type PageTarget struct {
+	ID        string
+	PlayOrder int
+	Type      string // One of the Page* constants
+	Value     int    // Page number for normal pages, or 0 if unknown
+	Label     string // Page number as printed, e.g. "iv" or "27"
+	Href      string // May have URL fragment
+}
+
+// A list of targets such as illustrations or tables
+type NavList struct {
+	Class   string // e.g. "lot" or "loi"
+	Label   string
+	Targets []NavTarget
+}
+
+type NavTarget struct {
+	ID        string
+	PlayOrder int
+	Label     string
+	Href      string // May have URL fragment
+}
+
+// Title types, as used by the EPUB 3 title-type property
+const (
+	TitleMain       = "main"
+	TitleSubtitle   = "subtitle"
+	TitleShort      = "short"
+	TitleCollection = "collection"
+	TitleEdition    = "edition"
+	TitleExpanded   = "expanded"
+)
+
+type Title struct {
+	Value      string `json:"value"`
+	Type       string `json:"type,omitempty"`       // One of the Title* constants, or empty if unspecified
+	DisplaySeq int    `json:"displaySeq,omitempty"` // Order for display, or 0 if unspecified
+	FileAs     string `json:"fileAs,omitempty"`
+}
+
+type Creator struct {
+	Name   string `json:"name"`
+	FileAs string `json:"fileAs,omitempty"`
+	Role   string `json:"role,omitempty"` // MARC relator code, e.g. aut
+}
+
+type Identifier struct {
+	Scheme string `json:"scheme,omitempty"` // e.g. ISBN, UUID
+	Value  string `json:"value"`
+}
+
+// Guide types, as used by the EPUB 2 guide
+const (
+	GuideCover     = "cover"
+	GuideTOC       = "toc"
+	GuideText      = "text"
+	GuideTitlePage = "title-page"
+)
+
+// Reference to a key part of the book, from the EPUB 2 guide or EPUB 3
+// landmarks
+type GuideItem struct {
+	Type  string // One of the Guide* constants, or another EPUB 2 guide type
+	Title string
+	Href  string // May have URL fragment
+}
+
 type Meta struct {
-	Name    string
-	Content string
+	Name    string `json:"name"`
+	Content string `json:"content"`
 }
 
 type Date struct {
-	Event string
-	Value string
+	Event string `json:"event,omitempty"`
+	Value string `json:"value"`
+}
+
+// The main title, which is the one edited as the "title" field
+func (m Metadata) MainTitle() string {
+	if i := m.MainTitleIndex(); i >= 0 {
+		return m.Titles[i].Value
+	}
+	return ""
 }
 
 func (b Book) GetResource(path string) (Resource, error) {
@@ -58,3 +196,41 @@ func (b Book) GetResource(path string) (Resource, error) {
 	}
 	return Resource{}, errors.New("can't find resource at path " + path)
 }
+
+// An ID based on base that no resource has, for adding a resource
+func UniqueResourceID(resources []Resource, base string) string {
+	ID := base
+	for i := 1; ; i++ {
+		clash := false
+		for _, r := range resources {
+			if r.ID == ID {
+				clash = true
+				break
+			}
+		}
+		if !clash {
+			return ID
+		}
+		ID = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// A path based on base that no resource has, numbering the file name before
+// its extension, like images/cover-1.jpg
+func UniqueResourcePath(resources []Resource, base string) string {
+	ext := path.Ext(base)
+	resourcePath := base
+	for i := 1; ; i++ {
+		clash := false
+		for _, r := range resources {
+			if r.Path == resourcePath {
+				clash = true
+				break
+			}
+		}
+		if !clash {
+			return resourcePath
+		}
+		resourcePath = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(base, ext), i, ext)
+	}
+}
@@ -0,0 +1,20 @@
+package book
+
+import "testing"
+
+func TestUniqueResource(t *testing.T) {
+	resources := []Resource{
+		{ID: "reprint-cover", Path: "images/reprint-cover.jpg"},
+		{ID: "reprint-cover-1", Path: "images/reprint-cover-1.jpg"},
+		{ID: "nav", Path: "toc.ncx"},
+	}
+	if got := UniqueResourceID(resources, "reprint-cover"); got != "reprint-cover-2" {
+		t.Errorf("UniqueResourceID = %q, want %q", got, "reprint-cover-2")
+	}
+	if got := UniqueResourcePath(resources, "images/reprint-cover.jpg"); got != "images/reprint-cover-2.jpg" {
+		t.Errorf("UniqueResourcePath = %q, want %q", got, "images/reprint-cover-2.jpg")
+	}
+	if got := UniqueResourcePath(resources, "nav.xhtml"); got != "nav.xhtml" {
+		t.Errorf("UniqueResourcePath = %q, want %q", got, "nav.xhtml")
+	}
+}
@@ -0,0 +1,515 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+
+	"github.com/asavoy/reprint/book"
+)
+
+var (
+	urlRegexp  = regexp.MustCompile(`url\("(.+?)"\)|url\('(.+?)'\)|url\((.+?)\)`)
+	attrRegexp = regexp.MustCompile(`(\s[^\s"'>/=]+\s*=\s*)("[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+)`)
+)
+
+// Where references are made
+const (
+	SourceXHTML = "xhtml" // Links, images, stylesheets and styles in pages
+	SourceCSS   = "css"   // url() in stylesheets
+	SourceSMIL  = "smil"  // Text and audio of media overlays
+	SourceNCX   = "ncx"   // TOC, page list and nav lists
+	SourceOPF   = "opf"   // Spine, cover, guide and media overlays
+)
+
+// A reference from one resource, or from the book's NCX or OPF, to another
+type Reference struct {
+	From   string // Path of the referencing resource, or empty for the NCX and OPF
+	To     string // Path of the referenced resource, which may be missing
+	Href   string // As written, or the resource ID for spine, cover and media overlay references
+	Source string // One of the Source* constants
+}
+
+// Index of a book's resources by ID and path, with the references between
+// them. Adding, removing and renaming resources through the graph keeps the
+// index, the references and the book consistent.
+type Graph struct {
+	b        *book.Book
+	byID     map[string]int
+	byPath   map[string]int
+	refs     map[string][]Reference // By From
+	backrefs map[string][]Reference // By To
+}
+
+func New(b *book.Book) *Graph {
+	g := &Graph{
+		b:        b,
+		refs:     map[string][]Reference{},
+		backrefs: map[string][]Reference{},
+	}
+	g.indexPositions()
+	for _, r := range b.Resources {
+		g.indexResource(r)
+	}
+	g.indexBook()
+	return g
+}
+
+func (g *Graph) ByID(ID string) (book.Resource, bool) {
+	i, ok := g.byID[ID]
+	if !ok {
+		return book.Resource{}, false
+	}
+	return g.b.Resources[i], true
+}
+
+func (g *Graph) ByPath(resourcePath string) (book.Resource, bool) {
+	i, ok := g.byPath[resourcePath]
+	if !ok {
+		return book.Resource{}, false
+	}
+	return g.b.Resources[i], true
+}
+
+// References made by a resource, or by the NCX and OPF for an empty path
+func (g *Graph) References(from string) []Reference {
+	return g.refs[from]
+}
+
+// References made to a resource
+func (g *Graph) ReferencedBy(to string) []Reference {
+	return g.backrefs[to]
+}
+
+func (g *Graph) Add(r book.Resource) error {
+	if _, ok := g.byPath[r.Path]; ok {
+		return fmt.Errorf("resource %s already exists", r.Path)
+	}
+	if _, ok := g.byID[r.ID]; ok {
+		return fmt.Errorf("resource ID %s already exists", r.ID)
+	}
+	g.b.Resources = append(g.b.Resources, r)
+	g.byID[r.ID] = len(g.b.Resources) - 1
+	g.byPath[r.Path] = len(g.b.Resources) - 1
+	g.indexResource(r)
+	return nil
+}
+
+// Remove a resource, along with its spine item, cover and media overlay
+// properties. References to it from elsewhere are left for the caller to deal
+// with.
+func (g *Graph) Remove(resourcePath string) error {
+	i, ok := g.byPath[resourcePath]
+	if !ok {
+		return fmt.Errorf("can't find resource at path %s", resourcePath)
+	}
+	r := g.b.Resources[i]
+	g.b.Resources = append(g.b.Resources[:i], g.b.Resources[i+1:]...)
+	var spineItems []book.SpineItem
+	for _, spineItem := range g.b.SpineItems {
+		if spineItem.ID != r.ID {
+			spineItems = append(spineItems, spineItem)
+		}
+	}
+	g.b.SpineItems = spineItems
+	if g.b.CoverImageID == r.ID {
+		g.b.CoverImageID = ""
+	}
+	for k := range g.b.Resources {
+		if g.b.Resources[k].MediaOverlay == r.ID {
+			g.b.Resources[k].MediaOverlay = ""
+		}
+	}
+	delete(g.b.MediaOverlays.Durations, r.ID)
+	g.unindexResource(r.Path)
+	g.indexPositions()
+	g.indexBook()
+	return nil
+}
+
+// Replace the contents of a resource, updating its references
+func (g *Graph) Update(resourcePath string, contents []byte) error {
+	i, ok := g.byPath[resourcePath]
+	if !ok {
+		return fmt.Errorf("can't find resource at path %s", resourcePath)
+	}
+	g.b.Resources[i].Contents = contents
+	g.unindexResource(resourcePath)
+	g.indexResource(g.b.Resources[i])
+	return nil
+}
+
+// Move a resource to a new path, rewriting every reference to it, and its own
+// relative references
+func (g *Graph) Rename(oldPath string, newPath string) error {
+	i, ok := g.byPath[oldPath]
+	if !ok {
+		return fmt.Errorf("can't find resource at path %s", oldPath)
+	}
+	if _, ok := g.byPath[newPath]; ok {
+		return fmt.Errorf("resource %s already exists", newPath)
+	}
+
+	// Rewrite the resource's own references, which are relative to it
+	contents := g.b.Resources[i].Contents
+	for _, ref := range g.refs[oldPath] {
+		if path.Dir(oldPath) == path.Dir(newPath) && ref.To != oldPath {
+			continue
+		}
+		to := ref.To
+		if to == oldPath {
+			to = newPath
+		}
+		contents = ReplaceHref(contents, ref.Href, RelHref(newPath, to, ref.Href))
+	}
+	g.b.Resources[i].Contents = contents
+	g.b.Resources[i].Path = newPath
+
+	referrers := g.redirectReferences(oldPath, newPath)
+	g.unindexResource(oldPath)
+	g.byPath[newPath] = i
+	delete(g.byPath, oldPath)
+	g.indexResource(g.b.Resources[i])
+	g.reindex(referrers)
+	g.indexBook()
+	return nil
+}
+
+// Point every reference to one resource at another instead, then remove it.
+// The spine, cover and media overlays refer to the remaining resource by its
+// ID.
+func (g *Graph) Merge(oldPath string, newPath string) error {
+	if oldPath == newPath {
+		return fmt.Errorf("can't merge resource %s into itself", oldPath)
+	}
+	i, ok := g.byPath[oldPath]
+	if !ok {
+		return fmt.Errorf("can't find resource at path %s", oldPath)
+	}
+	j, ok := g.byPath[newPath]
+	if !ok {
+		return fmt.Errorf("can't find resource at path %s", newPath)
+	}
+	oldID, newID := g.b.Resources[i].ID, g.b.Resources[j].ID
+
+	referrers := g.redirectReferences(oldPath, newPath)
+	for k := range g.b.SpineItems {
+		if g.b.SpineItems[k].ID == oldID {
+			g.b.SpineItems[k].ID = newID
+		}
+	}
+	if g.b.CoverImageID == oldID {
+		g.b.CoverImageID = newID
+	}
+	for k := range g.b.Resources {
+		if g.b.Resources[k].MediaOverlay == oldID {
+			g.b.Resources[k].MediaOverlay = newID
+		}
+	}
+	g.reindex(referrers)
+	return g.Remove(oldPath)
+}
+
+// Rewrite the references to a resource from other resources and the book to
+// lead to a new path, returning the paths of the referring resources
+func (g *Graph) redirectReferences(oldPath string, newPath string) []string {
+	var referrers []string
+	for _, ref := range g.backrefs[oldPath] {
+		if ref.From == "" || ref.From == oldPath {
+			continue
+		}
+		j := g.byPath[ref.From]
+		g.b.Resources[j].Contents = ReplaceHref(g.b.Resources[j].Contents, ref.Href, RelHref(ref.From, newPath, ref.Href))
+		referrers = append(referrers, ref.From)
+	}
+	renameBookHrefs(g.b, oldPath, newPath)
+	return referrers
+}
+
+func (g *Graph) reindex(resourcePaths []string) {
+	for _, resourcePath := range resourcePaths {
+		if i, ok := g.byPath[resourcePath]; ok {
+			g.unindexResource(resourcePath)
+			g.indexResource(g.b.Resources[i])
+		}
+	}
+}
+
+func (g *Graph) indexPositions() {
+	g.byID = map[string]int{}
+	g.byPath = map[string]int{}
+	for i, r := range g.b.Resources {
+		g.byID[r.ID] = i
+		g.byPath[r.Path] = i
+	}
+}
+
+func (g *Graph) addReference(ref Reference) {
+	g.refs[ref.From] = append(g.refs[ref.From], ref)
+	g.backrefs[ref.To] = append(g.backrefs[ref.To], ref)
+}
+
+// Remove the references made by a resource, or by the NCX and OPF
+func (g *Graph) unindexResource(from string) {
+	for _, ref := range g.refs[from] {
+		var backrefs []Reference
+		for _, backref := range g.backrefs[ref.To] {
+			if backref.From != from {
+				backrefs = append(backrefs, backref)
+			}
+		}
+		if len(backrefs) == 0 {
+			delete(g.backrefs, ref.To)
+		} else {
+			g.backrefs[ref.To] = backrefs
+		}
+	}
+	delete(g.refs, from)
+}
+
+func (g *Graph) indexResource(r book.Resource) {
+	var hrefs []string
+	var source string
+	switch r.MediaType {
+	case "application/xhtml+xml", "image/svg+xml":
+		source = SourceXHTML
+		hrefs = xhtmlHrefs(r.Contents)
+	case "application/smil+xml":
+		source = SourceSMIL
+		hrefs = SMILHrefs(r.Contents)
+	case "text/css":
+		source = SourceCSS
+		ReplaceURLs(string(r.Contents), func(u string) string {
+			hrefs = append(hrefs, u)
+			return u
+		})
+	default:
+		return
+	}
+	seen := map[string]bool{}
+	for _, href := range hrefs {
+		if seen[href] {
+			continue
+		}
+		seen[href] = true
+		if to, ok := resolveHref(r.Path, href); ok {
+			g.addReference(Reference{From: r.Path, To: to, Href: href, Source: source})
+		}
+	}
+}
+
+// Index the references made by the NCX and OPF
+func (g *Graph) indexBook() {
+	g.unindexResource("")
+	b := g.b
+	for _, spineItem := range b.SpineItems {
+		g.addIDReference(spineItem.ID)
+	}
+	if b.CoverImageID != "" {
+		g.addIDReference(b.CoverImageID)
+	}
+	for _, r := range b.Resources {
+		if r.MediaOverlay != "" {
+			g.addIDReference(r.MediaOverlay)
+		}
+	}
+	for _, guideItem := range b.GuideItems {
+		g.addReference(Reference{To: hrefPath(guideItem.Href), Href: guideItem.Href, Source: SourceOPF})
+	}
+	var walk func(tocItems []book.TOCItem)
+	walk = func(tocItems []book.TOCItem) {
+		for _, item := range tocItems {
+			g.addReference(Reference{To: hrefPath(item.Href), Href: item.Href, Source: SourceNCX})
+			walk(item.Children)
+		}
+	}
+	walk(b.TOCItems)
+	for _, pt := range b.PageTargets {
+		g.addReference(Reference{To: hrefPath(pt.Href), Href: pt.Href, Source: SourceNCX})
+	}
+	for _, navList := range b.NavLists {
+		for _, target := range navList.Targets {
+			g.addReference(Reference{To: hrefPath(target.Href), Href: target.Href, Source: SourceNCX})
+		}
+	}
+}
+
+func (g *Graph) addIDReference(ID string) {
+	to := ""
+	if r, ok := g.ByID(ID); ok {
+		to = r.Path
+	}
+	g.addReference(Reference{To: to, Href: ID, Source: SourceOPF})
+}
+
+// Hrefs of elements, including objects and video posters, and url() in
+// styles, of a page
+func xhtmlHrefs(contents []byte) []string {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(contents))
+	if err != nil {
+		return nil
+	}
+	var hrefs []string
+	doc.Find("*").Each(func(_ int, s *goquery.Selection) {
+		for _, attr := range s.Nodes[0].Attr {
+			switch attr.Key {
+			case "href", "src", "xlink:href", "data", "poster":
+				hrefs = append(hrefs, attr.Val)
+			case "style":
+				ReplaceURLs(attr.Val, func(u string) string {
+					hrefs = append(hrefs, u)
+					return u
+				})
+			}
+		}
+	})
+	ReplaceURLs(doc.Find("style").Text(), func(u string) string {
+		hrefs = append(hrefs, u)
+		return u
+	})
+	return hrefs
+}
+
+// Hrefs of the text and audio a media overlay synchronises, including the
+// epub:textref of its body and sequences
+func SMILHrefs(contents []byte) []string {
+	decoder := xml.NewDecoder(bytes.NewReader(contents))
+	decoder.Strict = false
+	var hrefs []string
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, attr := range element.Attr {
+			if attr.Name.Local == "src" || attr.Name.Local == "textref" {
+				hrefs = append(hrefs, attr.Value)
+			}
+		}
+	}
+	return hrefs
+}
+
+// The path of the resource an href leads to, unless it's external or only a
+// fragment
+func resolveHref(from string, href string) (string, bool) {
+	if u, err := url.Parse(href); err == nil && (u.Scheme != "" || u.Host != "") {
+		return "", false
+	}
+	hrefPath, _ := book.DecodeHref(href)
+	if hrefPath == "" {
+		return "", false
+	}
+	return book.AbsPath(from, hrefPath), true
+}
+
+// An href from one resource to another, keeping the fragment of href
+func RelHref(from string, to string, href string) string {
+	encoded := book.EncodeHref(book.RelPath(from, to), "")
+	if i := strings.Index(href, "#"); i >= 0 {
+		encoded += href[i:]
+	}
+	return encoded
+}
+
+// Replace an href in an attribute or url() of page, stylesheet or media
+// overlay source, leaving the rest untouched. Attributes are compared by
+// their values, so quoting and character references don't matter.
+func ReplaceHref(contents []byte, href string, newHref string) []byte {
+	if href == newHref {
+		return contents
+	}
+	replaceURL := func(u string) string {
+		if u == href {
+			return newHref
+		}
+		return u
+	}
+	var buf bytes.Buffer
+	z := html.NewTokenizer(bytes.NewReader(contents))
+	for {
+		tokenType := z.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		raw := string(z.Raw())
+		if tokenType == html.StartTagToken || tokenType == html.SelfClosingTagToken {
+			raw = replaceAttrs(raw, href, newHref)
+		}
+		buf.WriteString(ReplaceURLs(raw, replaceURL))
+	}
+	return buf.Bytes()
+}
+
+// Replace the values of a tag's attributes that are href, keeping their
+// quotes
+func replaceAttrs(tag string, href string, newHref string) string {
+	return attrRegexp.ReplaceAllStringFunc(tag, func(match string) string {
+		parts := attrRegexp.FindStringSubmatch(match)
+		name, value := parts[1], parts[2]
+		quote := `"`
+		if value[0] == '"' || value[0] == '\'' {
+			quote = value[:1]
+			value = value[1 : len(value)-1]
+		}
+		if html.UnescapeString(value) != href {
+			return match
+		}
+		return name + quote + html.EscapeString(newHref) + quote
+	})
+}
+
+// Replace each url() in CSS text with the result of replace on its URL,
+// keeping the quotes as they were written
+func ReplaceURLs(text string, replace func(url string) string) string {
+	return urlRegexp.ReplaceAllStringFunc(text, func(match string) string {
+		matches := urlRegexp.FindStringSubmatch(match)
+		url := matches[1] + matches[2] + matches[3]
+		return strings.Replace(match, url, replace(url), 1)
+	})
+}
+
+func renameBookHrefs(b *book.Book, oldPath string, newPath string) {
+	rename := func(href string) string {
+		if hrefPath(href) != oldPath {
+			return href
+		}
+		return newPath + strings.TrimPrefix(href, oldPath)
+	}
+	var walk func(tocItems []book.TOCItem)
+	walk = func(tocItems []book.TOCItem) {
+		for i := range tocItems {
+			tocItems[i].Href = rename(tocItems[i].Href)
+			walk(tocItems[i].Children)
+		}
+	}
+	walk(b.TOCItems)
+	for i := range b.GuideItems {
+		b.GuideItems[i].Href = rename(b.GuideItems[i].Href)
+	}
+	for i := range b.PageTargets {
+		b.PageTargets[i].Href = rename(b.PageTargets[i].Href)
+	}
+	for i := range b.NavLists {
+		for j := range b.NavLists[i].Targets {
+			b.NavLists[i].Targets[j].Href = rename(b.NavLists[i].Targets[j].Href)
+		}
+	}
+}
+
+// The path of an href in the book
+func hrefPath(href string) string {
+	hrefPath, _ := book.SplitHref(href)
+	return path.Clean(hrefPath)
+}
@@ -0,0 +1,243 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+)
+
+func TestLookup(t *testing.T) {
+	g := New(&book.Book{
+		Resources: []book.Resource{
+			{ID: "css", Path: "OEBPS/Styles/main.css", MediaType: "text/css"},
+			{ID: "a", Path: "OEBPS/Images/a.png", MediaType: "image/png"},
+		},
+	})
+	if r, ok := g.ByID("css"); !ok || r.Path != "OEBPS/Styles/main.css" {
+		t.Errorf("ByID(css) = %v, %v", r.Path, ok)
+	}
+	if r, ok := g.ByPath("OEBPS/Images/a.png"); !ok || r.ID != "a" {
+		t.Errorf("ByPath(OEBPS/Images/a.png) = %v, %v", r.ID, ok)
+	}
+	if _, ok := g.ByPath("OEBPS/Images/b.png"); ok {
+		t.Error("ByPath found a missing resource")
+	}
+}
+
+func TestReferences(t *testing.T) {
+	g := New(&book.Book{
+		Resources: []book.Resource{
+			{ID: "ch1", Path: "OEBPS/Text/Chapter 1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><head>
+<link href="../Styles/main.css" rel="stylesheet" type="text/css"/>
+</head><body>
+<p><a href="notes.xhtml#fn1">Note</a> <a href="http://example.com/">Web</a> <a href="#top">Top</a>
+<img src="../Images/a.png"/></p>
+</body></html>`)},
+			{ID: "notes", Path: "OEBPS/Text/notes.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><p id="fn1" style="background: url('../Images/a.png')">Note</p></body></html>`)},
+			{ID: "css", Path: "OEBPS/Styles/main.css", MediaType: "text/css", Contents: []byte(`@font-face { src: url(../Fonts/serif.otf); }`)},
+			{ID: "a", Path: "OEBPS/Images/a.png", MediaType: "image/png"},
+			{ID: "font", Path: "OEBPS/Fonts/serif.otf", MediaType: "font/otf"},
+		},
+		CoverImageID: "a",
+	})
+	got := g.References("OEBPS/Text/Chapter 1.xhtml")
+	want := []Reference{
+		{From: "OEBPS/Text/Chapter 1.xhtml", To: "OEBPS/Styles/main.css", Href: "../Styles/main.css", Source: SourceXHTML},
+		{From: "OEBPS/Text/Chapter 1.xhtml", To: "OEBPS/Text/notes.xhtml", Href: "notes.xhtml#fn1", Source: SourceXHTML},
+		{From: "OEBPS/Text/Chapter 1.xhtml", To: "OEBPS/Images/a.png", Href: "../Images/a.png", Source: SourceXHTML},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+
+	got = g.ReferencedBy("OEBPS/Images/a.png")
+	want = []Reference{
+		{From: "OEBPS/Text/Chapter 1.xhtml", To: "OEBPS/Images/a.png", Href: "../Images/a.png", Source: SourceXHTML},
+		{From: "OEBPS/Text/notes.xhtml", To: "OEBPS/Images/a.png", Href: "../Images/a.png", Source: SourceXHTML},
+		{To: "OEBPS/Images/a.png", Href: "a", Source: SourceOPF},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+
+	got = g.ReferencedBy("OEBPS/Fonts/serif.otf")
+	want = []Reference{
+		{From: "OEBPS/Styles/main.css", To: "OEBPS/Fonts/serif.otf", Href: "../Fonts/serif.otf", Source: SourceCSS},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestRename(t *testing.T) {
+	b := &book.Book{
+		Resources: []book.Resource{
+			{ID: "ch1", Path: "OEBPS/Text/Chapter 1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body>
+<p><a href="notes.xhtml#fn1">Note</a> <a href="http://example.com/">Web</a> <a href="#top">Top</a>
+<img src="../Images/a.png"/></p>
+</body></html>`)},
+			{ID: "notes", Path: "OEBPS/Text/notes.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><p id="fn1" style="background: url('../Images/a.png')">Note</p></body></html>`)},
+			{ID: "a", Path: "OEBPS/Images/a.png", MediaType: "image/png"},
+		},
+		CoverImageID: "a",
+		TOCItems:     []book.TOCItem{{Label: "Chapter 1", Href: "OEBPS/Text/Chapter 1.xhtml"}},
+		GuideItems:   []book.GuideItem{{Type: "notes", Title: "Notes", Href: "OEBPS/Text/notes.xhtml#fn1"}},
+	}
+	g := New(b)
+	if err := g.Rename("OEBPS/Text/notes.xhtml", "OEBPS/notes.xhtml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Rename("OEBPS/Images/a.png", "OEBPS/Images/cover image.png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Rename("OEBPS/Text/Chapter 1.xhtml", "OEBPS/Text/chapter1.xhtml"); err != nil {
+		t.Fatal(err)
+	}
+
+	wantChapter := `<html><body>
+<p><a href="../notes.xhtml#fn1">Note</a> <a href="http://example.com/">Web</a> <a href="#top">Top</a>
+<img src="../Images/cover%20image.png"/></p>
+</body></html>`
+	wantNotes := `<html><body><p id="fn1" style="background: url('Images/cover%20image.png')">Note</p></body></html>`
+	got := map[string]string{}
+	for _, r := range b.Resources {
+		got[r.Path] = string(r.Contents)
+	}
+	if diff := cmp.Diff(wantChapter, got["OEBPS/Text/chapter1.xhtml"]); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff(wantNotes, got["OEBPS/notes.xhtml"]); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff("OEBPS/Text/chapter1.xhtml", b.TOCItems[0].Href); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff("OEBPS/notes.xhtml#fn1", b.GuideItems[0].Href); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if _, ok := g.ByPath("OEBPS/Images/a.png"); ok {
+		t.Error("ByPath found a renamed resource at its old path")
+	}
+	if got := len(g.ReferencedBy("OEBPS/Images/cover image.png")); got != 3 {
+		t.Errorf("got %d references to the renamed image, want 3", got)
+	}
+	if err := g.Rename("OEBPS/notes.xhtml", "OEBPS/Text/chapter1.xhtml"); err == nil {
+		t.Error("renamed onto an existing resource")
+	}
+}
+
+func TestReplaceHref(t *testing.T) {
+	tests := []struct {
+		contents string
+		href     string
+		want     string
+	}{
+		{`<a href="Q&A.xhtml">`, "Q&A.xhtml", `<a href="new.xhtml">`},
+		{`<a href='Q&amp;A.xhtml'>`, "Q&A.xhtml", `<a href='new.xhtml'>`},
+		{`<img alt="Q&A" src = "Q&#38;A.xhtml" />`, "Q&A.xhtml", `<img alt="Q&A" src = "new.xhtml" />`},
+		{`<a href=Q&amp;A.xhtml>Q&amp;A.xhtml</a>`, "Q&A.xhtml", `<a href="new.xhtml">Q&amp;A.xhtml</a>`},
+		{`<p style="background: url('Q&A.xhtml')">`, "Q&A.xhtml", `<p style="background: url('new.xhtml')">`},
+		{`<text src="a.xhtml#p1"/><text src="a.xhtml#p2"/>`, "a.xhtml#p1", `<text src="new.xhtml"/><text src="a.xhtml#p2"/>`},
+		{`p { background: url("Q&A.xhtml"); }`, "Q&A.xhtml", `p { background: url("new.xhtml"); }`},
+	}
+	for _, test := range tests {
+		got := string(ReplaceHref([]byte(test.contents), test.href, "new.xhtml"))
+		if got != test.want {
+			t.Errorf("ReplaceHref(%q) = %q, want %q", test.contents, got, test.want)
+		}
+	}
+}
+
+func TestAddRemove(t *testing.T) {
+	b := &book.Book{
+		Resources: []book.Resource{
+			{ID: "ch1", Path: "OEBPS/Text/Chapter 1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><img src="../Images/a.png"/></body></html>`)},
+			{ID: "notes", Path: "OEBPS/Text/notes.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><p style="background: url('../Images/a.png')">Note</p></body></html>`)},
+			{ID: "a", Path: "OEBPS/Images/a.png", MediaType: "image/png"},
+		},
+		SpineItems:   []book.SpineItem{{ID: "ch1", Linear: true}, {ID: "notes", Linear: false}},
+		CoverImageID: "a",
+	}
+	g := New(b)
+	err := g.Add(book.Resource{ID: "ch2", Path: "OEBPS/Text/ch2.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><img src="../Images/a.png"/></body></html>`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Add(book.Resource{ID: "ch2", Path: "OEBPS/Text/other.xhtml"}); err == nil {
+		t.Error("added a resource with an existing ID")
+	}
+	if got := len(g.ReferencedBy("OEBPS/Images/a.png")); got != 4 {
+		t.Errorf("got %d references to the image, want 4", got)
+	}
+
+	if err := g.Remove("OEBPS/Text/notes.xhtml"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]book.SpineItem{{ID: "ch1", Linear: true}}, b.SpineItems); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if r, ok := g.ByID("ch2"); !ok || r.Path != "OEBPS/Text/ch2.xhtml" {
+		t.Errorf("ByID(ch2) = %v, %v", r.Path, ok)
+	}
+	if got := len(g.ReferencedBy("OEBPS/Images/a.png")); got != 3 {
+		t.Errorf("got %d references to the image, want 3", got)
+	}
+
+	if err := g.Update("OEBPS/Text/ch2.xhtml", []byte(`<html><body></body></html>`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.References("OEBPS/Text/ch2.xhtml"); len(got) != 0 {
+		t.Errorf("got references %v after update, want none", got)
+	}
+}
+
+func TestMediaOverlays(t *testing.T) {
+	b := &book.Book{
+		Resources: []book.Resource{
+			{ID: "ch1", Path: "OEBPS/Text/Chapter 1.xhtml", MediaType: "application/xhtml+xml", MediaOverlay: "smil1", Contents: []byte(`<html><body><p id="s1">One</p></body></html>`)},
+			{ID: "smil1", Path: "OEBPS/Audio/ch1.smil", MediaType: "application/smil+xml", Contents: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<smil xmlns="http://www.w3.org/ns/SMIL" xmlns:epub="http://www.idpf.org/2007/ops" version="3.0">
+<body epub:textref="../Text/Chapter%201.xhtml">
+<par id="p1"><text src="../Text/Chapter%201.xhtml#s1"/><audio src="ch1.mp3" clipBegin="0s" clipEnd="2.5s"/></par>
+</body>
+</smil>`)},
+			{ID: "mp3", Path: "OEBPS/Audio/ch1.mp3", MediaType: "audio/mpeg"},
+		},
+		MediaOverlays: book.MediaOverlays{Durations: map[string]string{"smil1": "0:00:02.500"}},
+	}
+	g := New(b)
+	want := []Reference{
+		{From: "OEBPS/Audio/ch1.smil", To: "OEBPS/Text/Chapter 1.xhtml", Href: "../Text/Chapter%201.xhtml", Source: SourceSMIL},
+		{From: "OEBPS/Audio/ch1.smil", To: "OEBPS/Text/Chapter 1.xhtml", Href: "../Text/Chapter%201.xhtml#s1", Source: SourceSMIL},
+		{From: "OEBPS/Audio/ch1.smil", To: "OEBPS/Audio/ch1.mp3", Href: "ch1.mp3", Source: SourceSMIL},
+	}
+	if diff := cmp.Diff(want, g.References("OEBPS/Audio/ch1.smil")); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff([]Reference{{To: "OEBPS/Audio/ch1.smil", Href: "smil1", Source: SourceOPF}}, g.ReferencedBy("OEBPS/Audio/ch1.smil")); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+
+	// Renaming a page rewrites the overlay pointing to it
+	if err := g.Rename("OEBPS/Text/Chapter 1.xhtml", "OEBPS/Text/ch1.xhtml"); err != nil {
+		t.Fatal(err)
+	}
+	smil, _ := g.ByID("smil1")
+	if got := string(smil.Contents); !strings.Contains(got, `epub:textref="../Text/ch1.xhtml"`) || !strings.Contains(got, `<text src="../Text/ch1.xhtml#s1"/>`) {
+		t.Errorf("overlay not rewritten:\n%s", got)
+	}
+
+	// Removing the overlay removes the page's media overlay property
+	if err := g.Remove("OEBPS/Audio/ch1.smil"); err != nil {
+		t.Fatal(err)
+	}
+	if page, _ := g.ByID("ch1"); page.MediaOverlay != "" {
+		t.Errorf("page still has media overlay %s", page.MediaOverlay)
+	}
+	if diff := cmp.Diff(map[string]string{}, b.MediaOverlays.Durations); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
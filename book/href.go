@@ -0,0 +1,94 @@
+package book
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Hrefs in the book, like TOCItem.Href, are a decoded path from the root of
+// the book, optionally followed by "#" and a fragment. A path that contains
+// "#" itself is always followed by one, even for an empty fragment. Hrefs in
+// documents are percent-encoded following IRI rules, and relative to the
+// document.
+
+// Split an href from a document into its decoded path and fragment. A "+" is
+// kept as it is, unlike in query strings, and a "%" that doesn't start an
+// escape is taken literally.
+func DecodeHref(href string) (string, string) {
+	hrefPath, fragment := href, ""
+	if i := strings.Index(href, "#"); i >= 0 {
+		hrefPath, fragment = href[:i], href[i+1:]
+	}
+	return unescape(hrefPath), unescape(fragment)
+}
+
+// An href for a document, percent-encoding only what IRIs don't allow, so
+// that spaces, "#" and "%" are encoded but "+" and non-ASCII letters aren't
+func EncodeHref(hrefPath string, fragment string) string {
+	href := escape(hrefPath, false)
+	if fragment != "" {
+		href += "#" + escape(fragment, true)
+	}
+	return href
+}
+
+// Split an href in the book into its path and fragment. Fragments can't
+// contain "#", but paths can.
+func SplitHref(href string) (string, string) {
+	if i := strings.LastIndex(href, "#"); i >= 0 {
+		return href[:i], href[i+1:]
+	}
+	return href, ""
+}
+
+// The href in the book for an href written in a document
+func ResolveHref(docPath string, href string) string {
+	hrefPath, fragment := DecodeHref(href)
+	resolved := docPath
+	if hrefPath != "" {
+		resolved = AbsPath(docPath, hrefPath)
+	}
+	if fragment != "" || strings.Contains(resolved, "#") {
+		resolved += "#" + fragment
+	}
+	return resolved
+}
+
+// The href to write in a document for an href in the book
+func RelHref(docPath string, href string) string {
+	hrefPath, fragment := SplitHref(href)
+	return EncodeHref(RelPath(docPath, hrefPath), fragment)
+}
+
+func unescape(s string) string {
+	unescaped, err := url.PathUnescape(s)
+	if err != nil {
+		return s
+	}
+	return unescaped
+}
+
+func escape(s string, isFragment bool) string {
+	var escaped strings.Builder
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			escaped.WriteString(fmt.Sprintf("%%%02X", s[0]))
+		case r >= utf8.RuneSelf:
+			// IRIs allow non-ASCII characters as they are
+			escaped.WriteRune(r)
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9',
+			strings.ContainsRune("-._~!$&'()*+,;=@/", r),
+			isFragment && (r == '?' || r == ':'):
+			escaped.WriteRune(r)
+		default:
+			// Including ":", which would otherwise be read as a URL scheme
+			escaped.WriteString(fmt.Sprintf("%%%02X", r))
+		}
+		s = s[size:]
+	}
+	return escaped.String()
+}
@@ -0,0 +1,65 @@
+package book
+
+import "testing"
+
+func TestHrefs(t *testing.T) {
+	tests := []struct {
+		path     string
+		fragment string
+		href     string
+	}{
+		{"C++ Primer.xhtml", "", "C++%20Primer.xhtml"},
+		{"Text/Chapter #1.xhtml", "top", "Text/Chapter%20%231.xhtml#top"},
+		{"100%.xhtml", "", "100%25.xhtml"},
+		{"Übersicht/Café.xhtml", "", "Übersicht/Café.xhtml"},
+		{"a:b.xhtml", "", "a%3Ab.xhtml"},
+		{"text/1.xhtml", "id?x", "text/1.xhtml#id?x"},
+	}
+	for _, test := range tests {
+		if got := EncodeHref(test.path, test.fragment); got != test.href {
+			t.Errorf("EncodeHref(%q, %q) = %q, want %q", test.path, test.fragment, got, test.href)
+		}
+		gotPath, gotFragment := DecodeHref(test.href)
+		if gotPath != test.path || gotFragment != test.fragment {
+			t.Errorf("DecodeHref(%q) = %q, %q, want %q, %q", test.href, gotPath, gotFragment, test.path, test.fragment)
+		}
+	}
+
+	// Literal "+" and stray "%" are kept as written
+	for href, want := range map[string]string{
+		"C++.xhtml":     "C++.xhtml",
+		"100%.xhtml":    "100%.xhtml",
+		"a%2Bb.xhtml":   "a+b.xhtml",
+		"Caf%C3%A9.css": "Café.css",
+	} {
+		if got, _ := DecodeHref(href); got != want {
+			t.Errorf("DecodeHref(%q) = %q, want %q", href, got, want)
+		}
+	}
+}
+
+func TestResolveHref(t *testing.T) {
+	tests := []struct {
+		docPath string
+		href    string
+		want    string
+	}{
+		{"OEBPS/Text/1.xhtml", "C++%20Primer.xhtml#c1", "OEBPS/Text/C++ Primer.xhtml#c1"},
+		{"OEBPS/Text/1.xhtml", "../Images/a%23b.png", "OEBPS/Images/a#b.png#"},
+		{"OEBPS/Text/1.xhtml", "#note", "OEBPS/Text/1.xhtml#note"},
+	}
+	for _, test := range tests {
+		got := ResolveHref(test.docPath, test.href)
+		if got != test.want {
+			t.Errorf("ResolveHref(%q, %q) = %q, want %q", test.docPath, test.href, got, test.want)
+		}
+		if rel := RelHref(test.docPath, got); ResolveHref(test.docPath, rel) != got {
+			t.Errorf("RelHref(%q, %q) = %q, which doesn't resolve back", test.docPath, got, rel)
+		}
+	}
+
+	hrefPath, fragment := SplitHref("OEBPS/Images/a#b.png#fig")
+	if hrefPath != "OEBPS/Images/a#b.png" || fragment != "fig" {
+		t.Errorf("SplitHref = %q, %q", hrefPath, fragment)
+	}
+}
@@ -0,0 +1,148 @@
+package json
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/asavoy/reprint/book"
+)
+
+// JSON structure of a book, stable enough to diff and edit with tools
+type Book struct {
+	Metadata book.Metadata `json:"metadata"`
+	Cover    string        `json:"cover,omitempty"` // Matches some Resource.ID
+	Spine    []SpineItem   `json:"spine"`
+	TOC      []TOCItem     `json:"toc"`
+	Manifest []Resource    `json:"manifest"`
+}
+
+type SpineItem struct {
+	ID         string   `json:"id"`
+	Linear     bool     `json:"linear"`
+	Properties []string `json:"properties,omitempty"`
+}
+
+type TOCItem struct {
+	Label    string    `json:"label"`
+	Href     string    `json:"href"`
+	Children []TOCItem `json:"children,omitempty"`
+}
+
+type Resource struct {
+	ID        string `json:"id"`
+	Path      string `json:"path"`
+	MediaType string `json:"mediaType"`
+	Size      int    `json:"size"`
+	SHA256    string `json:"sha256"`
+	Contents  []byte `json:"contents,omitempty"` // Only when exported with contents
+}
+
+func FromBook(b book.Book, withContents bool) Book {
+	jb := Book{
+		Metadata: b.Metadata,
+		Cover:    b.CoverImageID,
+		Spine:    []SpineItem{},
+		TOC:      fromTOCItems(b.TOCItems),
+		Manifest: []Resource{},
+	}
+	for _, spineItem := range b.SpineItems {
+		jb.Spine = append(jb.Spine, SpineItem{
+			ID:         spineItem.ID,
+			Linear:     spineItem.Linear,
+			Properties: spineItem.Properties,
+		})
+	}
+	for _, r := range b.Resources {
+		hash := sha256.Sum256(r.Contents)
+		resource := Resource{
+			ID:        r.ID,
+			Path:      r.Path,
+			MediaType: r.MediaType,
+			Size:      len(r.Contents),
+			SHA256:    hex.EncodeToString(hash[:]),
+		}
+		if withContents {
+			resource.Contents = r.Contents
+		}
+		jb.Manifest = append(jb.Manifest, resource)
+	}
+	return jb
+}
+
+func fromTOCItems(tocItems []book.TOCItem) []TOCItem {
+	items := []TOCItem{}
+	for _, tocItem := range tocItems {
+		items = append(items, TOCItem{
+			Label:    tocItem.Label,
+			Href:     tocItem.Href,
+			Children: fromTOCItems(tocItem.Children),
+		})
+	}
+	return items
+}
+
+func Marshal(b book.Book, withContents bool) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(FromBook(b, withContents))
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func Unmarshal(jsonBytes []byte) (Book, error) {
+	var jb Book
+	err := json.Unmarshal(jsonBytes, &jb)
+	if err != nil {
+		return Book{}, err
+	}
+	return jb, nil
+}
+
+// Apply the metadata, cover and TOC edits of a JSON book onto a book. Only
+// values that are set are applied, so partial edits leave the rest of the
+// book as it is. The spine and manifest are informational and aren't applied.
+func Apply(b *book.Book, jb Book) error {
+	if jb.Cover != "" {
+		found := false
+		for _, r := range b.Resources {
+			if r.ID == jb.Cover {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("can't find cover resource %s", jb.Cover)
+		}
+		b.CoverImageID = jb.Cover
+	}
+	b.Metadata.Merge(jb.Metadata)
+	if jb.TOC != nil {
+		playOrder := 0
+		b.TOCItems = toTOCItems(jb.TOC, &playOrder)
+	}
+	return nil
+}
+
+// Edited TOCs have no IDs or play orders, so these are assigned in order
+func toTOCItems(items []TOCItem, playOrder *int) []book.TOCItem {
+	var tocItems []book.TOCItem
+	for _, item := range items {
+		*playOrder++
+		tocItem := book.TOCItem{
+			ID:        fmt.Sprintf("navPoint-%d", *playOrder),
+			PlayOrder: *playOrder,
+			Label:     item.Label,
+			Href:      item.Href,
+		}
+		tocItem.Children = toTOCItems(item.Children, playOrder)
+		tocItems = append(tocItems, tocItem)
+	}
+	return tocItems
+}
@@ -0,0 +1,147 @@
+package json
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+)
+
+func TestMarshal(t *testing.T) {
+	b := book.Book{
+		Metadata: book.Metadata{
+			Titles:     []book.Title{{Value: "A Sample Book", Type: book.TitleMain}},
+			Identifier: "urn:uuid:1234",
+			Language:   "en",
+		},
+		Resources: []book.Resource{
+			{ID: "p1", Path: "text/1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte("<p>1</p>")},
+		},
+		SpineItems: []book.SpineItem{{ID: "p1", Linear: true}},
+		TOCItems: []book.TOCItem{
+			{ID: "n1", PlayOrder: 1, Label: "Chapter 1", Href: "text/1.xhtml"},
+		},
+	}
+	got, err := Marshal(b, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "metadata": {
+    "titles": [
+      {
+        "value": "A Sample Book",
+        "type": "main"
+      }
+    ],
+    "identifier": "urn:uuid:1234",
+    "language": "en"
+  },
+  "spine": [
+    {
+      "id": "p1",
+      "linear": true
+    }
+  ],
+  "toc": [
+    {
+      "label": "Chapter 1",
+      "href": "text/1.xhtml"
+    }
+  ],
+  "manifest": [
+    {
+      "id": "p1",
+      "path": "text/1.xhtml",
+      "mediaType": "application/xhtml+xml",
+      "size": 8,
+      "sha256": "16de9dfa12664d7331c84ac261051ef5ebe566d03dae3faf5e45825fb04bcdbc"
+    }
+  ]
+}
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestApply(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{{ID: "img"}},
+		TOCItems:  []book.TOCItem{{ID: "n1", PlayOrder: 1, Label: "Start", Href: "1.xhtml"}},
+	}
+	jb, err := Unmarshal([]byte(`{
+  "metadata": {"titles": [{"value": "Edited"}]},
+  "cover": "img",
+  "toc": [
+    {"label": "Part 1", "href": "1.xhtml", "children": [{"label": "Chapter 1", "href": "1.xhtml#c1"}]},
+    {"label": "Part 2", "href": "2.xhtml"}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Apply(&b, jb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := book.Book{
+		Metadata:     book.Metadata{Titles: []book.Title{{Value: "Edited"}}},
+		Resources:    []book.Resource{{ID: "img"}},
+		CoverImageID: "img",
+		TOCItems: []book.TOCItem{
+			{ID: "navPoint-1", PlayOrder: 1, Label: "Part 1", Href: "1.xhtml", Children: []book.TOCItem{
+				{ID: "navPoint-2", PlayOrder: 2, Label: "Chapter 1", Href: "1.xhtml#c1"},
+			}},
+			{ID: "navPoint-3", PlayOrder: 3, Label: "Part 2", Href: "2.xhtml"},
+		},
+	}
+	if diff := cmp.Diff(want, b); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestApplyPartial(t *testing.T) {
+	b := book.Book{
+		Metadata: book.Metadata{
+			Titles:     []book.Title{{Value: "A Sample Book"}},
+			Identifier: "urn:uuid:1",
+			Language:   "en",
+		},
+		Resources:    []book.Resource{{ID: "img"}},
+		CoverImageID: "img",
+		TOCItems:     []book.TOCItem{{ID: "n1", PlayOrder: 1, Label: "Start", Href: "1.xhtml"}},
+	}
+	tests := []struct {
+		json string
+		want book.Book
+	}{
+		{`{}`, b},
+		{`{"metadata": {}, "cover": ""}`, b},
+		{`{"metadata": {"language": "fr"}}`, book.Book{
+			Metadata: book.Metadata{
+				Titles:     []book.Title{{Value: "A Sample Book"}},
+				Identifier: "urn:uuid:1",
+				Language:   "fr",
+			},
+			Resources:    []book.Resource{{ID: "img"}},
+			CoverImageID: "img",
+			TOCItems:     []book.TOCItem{{ID: "n1", PlayOrder: 1, Label: "Start", Href: "1.xhtml"}},
+		}},
+	}
+	for _, test := range tests {
+		jb, err := Unmarshal([]byte(test.json))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := b
+		err = Apply(&got, jb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: got != want:\n%s", test.json, diff)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package book
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Names of the metadata fields that can be edited by name. Identifiers other
+// than the unique identifier are named by scheme, e.g. identifier:isbn.
+var FieldNames = []string{
+	"title",
+	"subtitle",
+	"title-sort",
+	"creator",
+	"author-sort",
+	"publisher",
+	"language",
+	"subject",
+	"series",
+	"series-index",
+	"description",
+	"rights",
+	"cover",
+	"identifier",
+}
+
+// A named metadata value, as shown to users
+type Field struct {
+	Name  string
+	Value string
+}
+
+// List the book's metadata fields that have values, in FieldNames order
+func (b Book) Fields() []Field {
+	var fields []Field
+	for _, name := range FieldNames {
+		for _, value := range b.getField(name) {
+			fields = append(fields, Field{Name: name, Value: value})
+		}
+		if name == "identifier" {
+			for _, identifier := range b.Identifiers {
+				fields = append(fields, Field{
+					Name:  "identifier:" + strings.ToLower(identifier.Scheme),
+					Value: identifier.Value,
+				})
+			}
+		}
+	}
+	return fields
+}
+
+func (b Book) getField(name string) []string {
+	switch name {
+	case "title":
+		return nonEmpty(b.MainTitle())
+	case "subtitle":
+		if i := b.titleIndex(TitleSubtitle); i >= 0 {
+			return nonEmpty(b.Titles[i].Value)
+		}
+	case "title-sort":
+		if i := b.MainTitleIndex(); i >= 0 {
+			return nonEmpty(b.Titles[i].FileAs)
+		}
+	case "creator":
+		return b.CreatorNames()
+	case "author-sort":
+		var sorts []string
+		for _, creator := range b.Creators {
+			sorts = append(sorts, creator.FileAs)
+		}
+		return nonEmpty(strings.Trim(strings.Join(sorts, " & "), " &"))
+	case "publisher":
+		return nonEmpty(b.Publisher)
+	case "language":
+		return nonEmpty(b.Language)
+	case "subject":
+		return b.Subjects
+	case "series":
+		return nonEmpty(b.Series)
+	case "series-index":
+		return nonEmpty(b.SeriesIndex)
+	case "description":
+		return nonEmpty(b.Description)
+	case "rights":
+		return nonEmpty(b.Rights)
+	case "cover":
+		for _, r := range b.Resources {
+			if r.ID == b.CoverImageID {
+				return []string{r.Path}
+			}
+		}
+	case "identifier":
+		return nonEmpty(b.Identifier)
+	}
+	return nil
+}
+
+// Replace all values of a field with the given value
+func (b *Book) SetField(name string, value string) error {
+	if scheme, ok := identifierScheme(name); ok {
+		b.removeIdentifiers(scheme, "")
+		b.Identifiers = append(b.Identifiers, Identifier{Scheme: scheme, Value: value})
+		return nil
+	}
+	switch name {
+	case "title":
+		if i := b.MainTitleIndex(); i >= 0 {
+			b.Titles[i].Value = value
+		} else {
+			b.Titles = append(b.Titles, Title{Value: value, Type: TitleMain})
+		}
+	case "subtitle":
+		if i := b.titleIndex(TitleSubtitle); i >= 0 {
+			b.Titles[i].Value = value
+		} else {
+			b.Titles = append(b.Titles, Title{Value: value, Type: TitleSubtitle})
+		}
+	case "title-sort":
+		i := b.MainTitleIndex()
+		if i < 0 {
+			return fmt.Errorf("can't set %s without a title", name)
+		}
+		b.Titles[i].FileAs = value
+	case "creator":
+		b.Creators = []Creator{{Name: value}}
+	case "author-sort":
+		// Sorts are given for each creator in order, like "Doe, Jane & Roe, Rick"
+		sorts := strings.Split(value, "&")
+		if len(sorts) != len(b.Creators) {
+			return fmt.Errorf("%s has %d names but the book has %d creators", name, len(sorts), len(b.Creators))
+		}
+		for i := range b.Creators {
+			b.Creators[i].FileAs = strings.TrimSpace(sorts[i])
+		}
+	case "publisher":
+		b.Publisher = value
+	case "language":
+		b.Language = value
+	case "subject":
+		b.Subjects = []string{value}
+	case "series":
+		b.Series = value
+	case "series-index":
+		b.SeriesIndex = value
+	case "description":
+		b.Description = value
+	case "rights":
+		b.Rights = value
+	case "cover":
+		for _, r := range b.Resources {
+			if r.Path == value || r.ID == value {
+				b.CoverImageID = r.ID
+				return nil
+			}
+		}
+		return fmt.Errorf("can't find cover resource %s", value)
+	case "identifier":
+		b.Identifier = value
+	default:
+		return fmt.Errorf("unknown field %s", name)
+	}
+	return nil
+}
+
+// Add a value to a field that can have many values
+func (b *Book) AddField(name string, value string) error {
+	if scheme, ok := identifierScheme(name); ok {
+		b.Identifiers = append(b.Identifiers, Identifier{Scheme: scheme, Value: value})
+		return nil
+	}
+	switch name {
+	case "creator":
+		b.Creators = append(b.Creators, Creator{Name: value})
+	case "subject":
+		b.Subjects = append(b.Subjects, value)
+	default:
+		if !isField(name) {
+			return fmt.Errorf("unknown field %s", name)
+		}
+		return fmt.Errorf("%s can only have one value", name)
+	}
+	return nil
+}
+
+// Remove a value from a field, or all values if value is empty
+func (b *Book) RemoveField(name string, value string) error {
+	if scheme, ok := identifierScheme(name); ok {
+		b.removeIdentifiers(scheme, value)
+		return nil
+	}
+	switch name {
+	case "title":
+		if i := b.MainTitleIndex(); i >= 0 {
+			b.Titles = append(b.Titles[:i], b.Titles[i+1:]...)
+		}
+	case "subtitle":
+		if i := b.titleIndex(TitleSubtitle); i >= 0 {
+			b.Titles = append(b.Titles[:i], b.Titles[i+1:]...)
+		}
+	case "title-sort":
+		if i := b.MainTitleIndex(); i >= 0 {
+			b.Titles[i].FileAs = ""
+		}
+	case "creator":
+		var creators []Creator
+		for _, creator := range b.Creators {
+			if value != "" && creator.Name != value {
+				creators = append(creators, creator)
+			}
+		}
+		b.Creators = creators
+	case "author-sort":
+		for i := range b.Creators {
+			b.Creators[i].FileAs = ""
+		}
+	case "publisher":
+		b.Publisher = ""
+	case "language":
+		b.Language = ""
+	case "subject":
+		var subjects []string
+		for _, subject := range b.Subjects {
+			if value != "" && subject != value {
+				subjects = append(subjects, subject)
+			}
+		}
+		b.Subjects = subjects
+	case "series":
+		b.Series = ""
+		b.SeriesIndex = ""
+	case "series-index":
+		b.SeriesIndex = ""
+	case "description":
+		b.Description = ""
+	case "rights":
+		b.Rights = ""
+	case "cover":
+		b.CoverImageID = ""
+	case "identifier":
+		return fmt.Errorf("can't remove the unique identifier")
+	default:
+		return fmt.Errorf("unknown field %s", name)
+	}
+	return nil
+}
+
+// Override metadata with every value that's set in other
+func (m *Metadata) Merge(other Metadata) {
+	if len(other.Titles) > 0 {
+		m.Titles = other.Titles
+	}
+	if other.Identifier != "" {
+		m.Identifier = other.Identifier
+	}
+	if len(other.Identifiers) > 0 {
+		m.Identifiers = other.Identifiers
+	}
+	if len(other.Creators) > 0 {
+		m.Creators = other.Creators
+	}
+	if other.Publisher != "" {
+		m.Publisher = other.Publisher
+	}
+	if other.Language != "" {
+		m.Language = other.Language
+	}
+	if len(other.Subjects) > 0 {
+		m.Subjects = other.Subjects
+	}
+	if other.Description != "" {
+		m.Description = other.Description
+	}
+	if other.Series != "" {
+		m.Series = other.Series
+		m.SeriesIndex = other.SeriesIndex
+	}
+	if other.Rights != "" {
+		m.Rights = other.Rights
+	}
+	if other.Source != "" {
+		m.Source = other.Source
+	}
+	if len(other.Dates) > 0 {
+		m.Dates = other.Dates
+	}
+}
+
+// Index of the main title, or -1 if there's none. When none are typed main,
+// it's the first untyped title, or failing that the first title that isn't
+// a subtitle.
+func (m Metadata) MainTitleIndex() int {
+	if i := m.titleIndex(TitleMain); i >= 0 {
+		return i
+	}
+	if i := m.titleIndex(""); i >= 0 {
+		return i
+	}
+	for i, t := range m.Titles {
+		if t.Type != TitleSubtitle {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m Metadata) titleIndex(titleType string) int {
+	for i, t := range m.Titles {
+		if t.Type == titleType {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Metadata) removeIdentifiers(scheme string, value string) {
+	var identifiers []Identifier
+	for _, identifier := range m.Identifiers {
+		if !strings.EqualFold(identifier.Scheme, scheme) || (value != "" && identifier.Value != value) {
+			identifiers = append(identifiers, identifier)
+		}
+	}
+	m.Identifiers = identifiers
+}
+
+func identifierScheme(name string) (string, bool) {
+	if !strings.HasPrefix(name, "identifier:") {
+		return "", false
+	}
+	return strings.ToUpper(strings.TrimPrefix(name, "identifier:")), true
+}
+
+func isField(name string) bool {
+	for _, fieldName := range FieldNames {
+		if fieldName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m Metadata) CreatorNames() []string {
+	var names []string
+	for _, creator := range m.Creators {
+		names = append(names, creator.Name)
+	}
+	return names
+}
+
+func nonEmpty(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
+
+// Generate a name-based (version 5 style) UUID URN from the book's contents,
+// so that the same book always gets the same identifier
+func GenerateUUID(b Book) string {
+	resources := make([]Resource, len(b.Resources))
+	copy(resources, b.Resources)
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Path < resources[j].Path
+	})
+
+	hash := sha1.New()
+	for _, title := range b.Titles {
+		hash.Write([]byte(title.Value + "\x00"))
+	}
+	for _, creator := range b.Creators {
+		hash.Write([]byte(creator.Name + "\x00"))
+	}
+	for _, r := range resources {
+		hash.Write([]byte(r.Path + "\x00"))
+		hash.Write(r.Contents)
+	}
+	sum := hash.Sum(nil)
+
+	sum[6] = (sum[6] & 0x0f) | 0x50 // Version 5
+	sum[8] = (sum[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,109 @@
+package book
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEditFields(t *testing.T) {
+	b := Book{
+		Metadata: Metadata{
+			Titles: []Title{
+				{Value: "Go in Practice"},
+				{Value: "Includes 70 Techniques", Type: TitleSubtitle},
+			},
+			Identifier: "urn:uuid:1234",
+			Creators:   []Creator{{Name: "Matt Butcher"}, {Name: "Matt Farina"}},
+			Subjects:   []string{"Programming"},
+		},
+		Resources: []Resource{
+			{ID: "img", Path: "images/cover.jpg"},
+		},
+	}
+	edits := []struct {
+		op    func(string, string) error
+		name  string
+		value string
+	}{
+		{b.SetField, "title", "Go In Practice"},
+		{b.SetField, "title-sort", "Go in Practice"},
+		{b.SetField, "author-sort", "Butcher, Matt & Farina, Matt"},
+		{b.AddField, "subject", "Go"},
+		{b.RemoveField, "subject", "Programming"},
+		{b.AddField, "identifier:isbn", "9781617291784"},
+		{b.SetField, "cover", "images/cover.jpg"},
+		{b.RemoveField, "subtitle", ""},
+	}
+	for _, edit := range edits {
+		if err := edit.op(edit.name, edit.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := b.Fields()
+	want := []Field{
+		{Name: "title", Value: "Go In Practice"},
+		{Name: "title-sort", Value: "Go in Practice"},
+		{Name: "creator", Value: "Matt Butcher"},
+		{Name: "creator", Value: "Matt Farina"},
+		{Name: "author-sort", Value: "Butcher, Matt & Farina, Matt"},
+		{Name: "subject", Value: "Go"},
+		{Name: "cover", Value: "images/cover.jpg"},
+		{Name: "identifier", Value: "urn:uuid:1234"},
+		{Name: "identifier:isbn", Value: "9781617291784"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+
+	if err := b.AddField("title", "Another"); err == nil {
+		t.Error("expected error adding to a single valued field")
+	}
+	if err := b.SetField("author-sort", "Butcher, Matt"); err == nil {
+		t.Error("expected error for mismatched author-sort")
+	}
+}
+
+func TestMainTitle(t *testing.T) {
+	tests := []struct {
+		titles []Title
+		want   string
+	}{
+		{[]Title{{Value: "Subtitle", Type: TitleSubtitle}, {Value: "Main", Type: TitleMain}}, "Main"},
+		{[]Title{{Value: "Series", Type: "collection"}, {Value: "Untyped"}}, "Untyped"},
+		{[]Title{{Value: "Subtitle", Type: TitleSubtitle}, {Value: "Expanded", Type: "expanded"}}, "Expanded"},
+		{[]Title{{Value: "Subtitle", Type: TitleSubtitle}}, ""},
+	}
+	for _, test := range tests {
+		b := Book{Metadata: Metadata{Titles: append([]Title(nil), test.titles...)}}
+		if got := b.MainTitle(); got != test.want {
+			t.Errorf("%v: MainTitle() = %q, want %q", test.titles, got, test.want)
+		}
+		// The title field is the main title
+		if err := b.SetField("title", "Edited"); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.MainTitle(); got != "Edited" {
+			t.Errorf("%v: MainTitle() after SetField = %q, want %q", test.titles, got, "Edited")
+		}
+	}
+}
+
+func TestGenerateUUID(t *testing.T) {
+	b := Book{
+		Resources: []Resource{
+			{Path: "2.xhtml", Contents: []byte("<p>2</p>")},
+			{Path: "1.xhtml", Contents: []byte("<p>1</p>")},
+		},
+	}
+	reordered := Book{
+		Resources: []Resource{b.Resources[1], b.Resources[0]},
+	}
+	got := GenerateUUID(b)
+	if got != GenerateUUID(reordered) {
+		t.Error("UUID isn't stable:", got)
+	}
+	if len(got) != len("urn:uuid:00000000-0000-5000-8000-000000000000") || got[23] != '5' {
+		t.Error("not a version 5 UUID URN:", got)
+	}
+}
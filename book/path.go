@@ -0,0 +1,32 @@
+package book
+
+import (
+	"path"
+	"strings"
+)
+
+// Resolve a path relative to the document it's in, to a path from the root of
+// the book
+func AbsPath(docPath string, relPath string) string {
+	return path.Clean(path.Join(path.Dir(docPath), relPath))
+}
+
+// The path to a file from the document it's in, where both are paths from the
+// root of the book
+func RelPath(docPath string, targetPath string) string {
+	var fromParts []string
+	if dir := path.Dir(docPath); dir != "." {
+		fromParts = strings.Split(dir, "/")
+	}
+	toParts := strings.Split(path.Clean(targetPath), "/")
+	common := 0
+	for common < len(fromParts) && common < len(toParts)-1 && fromParts[common] == toParts[common] {
+		common++
+	}
+	relParts := make([]string, 0, len(fromParts)-common+len(toParts)-common)
+	for range fromParts[common:] {
+		relParts = append(relParts, "..")
+	}
+	relParts = append(relParts, toParts[common:]...)
+	return strings.Join(relParts, "/")
+}
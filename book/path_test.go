@@ -0,0 +1,27 @@
+package book
+
+import "testing"
+
+func TestRelPath(t *testing.T) {
+	tests := []struct {
+		docPath    string
+		targetPath string
+		want       string
+	}{
+		{"text/1.xhtml", "text/2.xhtml", "2.xhtml"},
+		{"text/1.xhtml", "images/a.png", "../images/a.png"},
+		{"OEBPS/Text/1.xhtml", "OEBPS/Images/a.png", "../Images/a.png"},
+		{"1.xhtml", "images/a.png", "images/a.png"},
+		{"a/b/c/1.xhtml", "a/2.xhtml", "../../2.xhtml"},
+		{"text/1.xhtml", "text", "../text"},
+	}
+	for _, test := range tests {
+		got := RelPath(test.docPath, test.targetPath)
+		if got != test.want {
+			t.Errorf("RelPath(%q, %q) = %q, want %q", test.docPath, test.targetPath, got, test.want)
+		}
+		if abs := AbsPath(test.docPath, got); abs != test.targetPath {
+			t.Errorf("AbsPath(%q, %q) = %q, want %q", test.docPath, got, abs, test.targetPath)
+		}
+	}
+}
@@ -5,38 +5,119 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"regexp"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/vanng822/css"
 	"golang.org/x/net/html"
 
 	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/book/graph"
 	cleanCSS "github.com/asavoy/reprint/clean/css"
+	cleanDedupe "github.com/asavoy/reprint/clean/dedupe"
 	cleanHTML "github.com/asavoy/reprint/clean/html"
+	cleanLinks "github.com/asavoy/reprint/clean/links"
+	cleanMediaType "github.com/asavoy/reprint/clean/mediatype"
+	cleanMetadata "github.com/asavoy/reprint/clean/metadata"
+	cleanPrune "github.com/asavoy/reprint/clean/prune"
+	cleanSpine "github.com/asavoy/reprint/clean/spine"
+	cleanTOC "github.com/asavoy/reprint/clean/toc"
 )
 
-func Clean(b *book.Book) error {
+// Clean the book, returning warnings about problems that didn't stop it
+func Clean(b *book.Book) ([]string, error) {
+	var warnings []string
+	// Everything after this goes by media type, so it had better be right
+	warnings = append(warnings, cleanMediaType.Correct(b)...)
+	warnings = append(warnings, cleanMetadata.NormaliseIdentifiers(b)...)
+	warnings = append(warnings, cleanMetadata.NormaliseDates(b)...)
+	warnings = append(warnings, cleanMetadata.NormaliseBookLanguage(b)...)
+	// Repairing the spine guesses at which pages are notes or ads, so is left
+	// to the spine command
+	warnings = append(warnings, cleanSpine.Check(*b)...)
+
+	// Pages of fixed layout books are positioned by their styles and markup,
+	// so any pass that rewrites them could jumble them
+	fixedLayout := isFixedLayout(*b)
+	if !fixedLayout && cleanTOC.Useless(*b) {
+		err := cleanTOC.Generate(b, cleanTOC.Options{Depth: cleanTOC.DefaultDepth})
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Nesting and casing are the publisher's choice, so are only changed by
+	// the toc command
+	cleanTOC.Tidy(b, cleanTOC.TidyOptions{})
+
+	if fixedLayout {
+		warnings = append(warnings, "left pages and styles as they are, as the book has a fixed layout")
+		for _, problem := range cleanLinks.Check(*b) {
+			warnings = append(warnings, "broken link "+problem.String())
+		}
+	} else {
+		for _, problem := range cleanLinks.Fix(b) {
+			warnings = append(warnings, "broken link "+problem.String())
+		}
+		warnings = append(warnings, cleanDedupe.Dedupe(b)...)
+		fixedPages := fixedLayoutPages(*b)
+		for _, r := range b.Resources {
+			if fixedPages[r.Path] {
+				warnings = append(warnings, fmt.Sprintf("left %s and its styles as they are, as it has a fixed layout", r.Path))
+			}
+		}
+		if err := cleanPages(b, fixedPages); err != nil {
+			return nil, err
+		}
+	}
+
+	// Fonts and images only used by removed styles are no longer needed
+	for _, resourcePath := range cleanPrune.Prune(b, cleanPrune.Options{}) {
+		warnings = append(warnings, "removed unreferenced resource "+resourcePath)
+	}
+	return warnings, nil
+}
+
+// Clean the styles and markup of every page except those at skipPaths,
+// merging linked stylesheets into the pages. Stylesheets that skipped pages
+// link to are kept.
+func cleanPages(b *book.Book, skipPaths map[string]bool) error {
+	protected, err := linkedIDs(*b)
+	if err != nil {
+		return err
+	}
+
+	g := graph.New(b)
 	var newResources []book.Resource
 	deleteResourceByPath := make(map[string]bool)
+	keepResourceByPath := make(map[string]bool)
 
 	for _, resource := range b.Resources {
+		if skipPaths[resource.Path] {
+			for _, ref := range g.References(resource.Path) {
+				keepResourceByPath[ref.To] = true
+			}
+			continue
+		}
 		if resource.MediaType == "application/xhtml+xml" {
 			resource.Contents = []byte(cleanHTML.ConvertXHTMLToHTML(string(resource.Contents)))
-			doc, ss, ssResources, err := decomposePage(resource, *b)
+			doc, ss, ssResources, err := decomposePage(resource, g)
 			if err != nil {
 				return err
 			}
 
-			cleanPage(doc, ss)
+			cleanPage(doc, ss, protected[resource.Path])
+			setPageLanguage(doc, b.Language)
 			docHTML, err := doc.Html()
 			if err != nil {
 				return err
 			}
 			newResources = append(newResources, book.Resource{
-				ID:        resource.ID,
-				Path:      resource.Path,
-				MediaType: resource.MediaType,
-				Contents:  []byte(docHTML),
+				ID:           resource.ID,
+				Path:         resource.Path,
+				MediaType:    resource.MediaType,
+				Properties:   resource.Properties,
+				MediaOverlay: resource.MediaOverlay,
+				Contents:     []byte(docHTML),
 			})
 
 			deleteResourceByPath[resource.Path] = true
@@ -51,7 +132,7 @@ func Clean(b *book.Book) error {
 		resources = append(resources, r)
 	}
 	for _, r := range b.Resources {
-		if _, ok := deleteResourceByPath[r.Path]; !ok {
+		if _, ok := deleteResourceByPath[r.Path]; !ok || keepResourceByPath[r.Path] {
 			resources = append(resources, r)
 		}
 	}
@@ -60,7 +141,59 @@ func Clean(b *book.Book) error {
 	return nil
 }
 
-func cleanPage(doc *goquery.Document, ss *css.CSSStyleSheet) {
+// Whether the book has a fixed layout, going by its rendition properties, or
+// by every page in the spine having a viewport size
+func isFixedLayout(b book.Book) bool {
+	if b.FixedLayout() {
+		return true
+	}
+	if b.Rendition.Layout == book.LayoutReflowable || len(b.SpineItems) == 0 {
+		return false
+	}
+	resourcesByID := map[string]book.Resource{}
+	for _, r := range b.Resources {
+		resourcesByID[r.ID] = r
+	}
+	pages := 0
+	for _, spineItem := range b.SpineItems {
+		r, ok := resourcesByID[spineItem.ID]
+		if !ok || r.MediaType != "application/xhtml+xml" {
+			continue
+		}
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Contents))
+		if err != nil {
+			return false
+		}
+		viewport := doc.Find(`meta[name="viewport"]`).AttrOr("content", "")
+		if !viewportSizeRegexp.MatchString(viewport) {
+			return false
+		}
+		pages++
+	}
+	return pages > 0
+}
+
+// Paths of the pages a reflowable book lays out at a fixed size, going by
+// their spine items' properties
+func fixedLayoutPages(b book.Book) map[string]bool {
+	resourcesByID := map[string]book.Resource{}
+	for _, r := range b.Resources {
+		resourcesByID[r.ID] = r
+	}
+	paths := map[string]bool{}
+	for _, spineItem := range b.SpineItems {
+		for _, property := range spineItem.Properties {
+			if r, ok := resourcesByID[spineItem.ID]; ok && property == "rendition:layout-"+book.LayoutPrePaginated {
+				paths[r.Path] = true
+			}
+		}
+	}
+	return paths
+}
+
+var viewportSizeRegexp = regexp.MustCompile(`(?i)width\s*=\s*\d+.*height\s*=\s*\d+|height\s*=\s*\d+.*width\s*=\s*\d+`)
+
+func cleanPage(doc *goquery.Document, ss *css.CSSStyleSheet, protected cleanHTML.ProtectedIDs) {
 	extractInlineStyles(doc, ss)
 	imageSS := extractImageStyles(doc, ss)
 
@@ -77,12 +210,13 @@ func cleanPage(doc *goquery.Document, ss *css.CSSStyleSheet) {
 	cleanCSS.AddAsideStyles(ss)
 	cleanCSS.AddTableStyles(ss)
 
-	cleanHTML.RemoveEmptySpans(doc)
+	cleanHTML.RemoveEmptySpans(doc, protected)
 	cleanHTML.RemoveEmptyDivs(doc)
-	cleanHTML.RemoveLineBreaks(doc)
+	cleanHTML.RemoveLineBreaks(doc, protected)
 	cleanHTML.RemoveContainers(doc)
-	cleanHTML.RemoveBoldInHeadings(doc)
-	cleanHTML.RemoveExcessBlockquotes(doc)
+	cleanHTML.RemoveBoldInHeadings(doc, protected)
+	cleanHTML.RemoveExcessBlockquotes(doc, protected)
+	cleanHTML.AddMathAltText(doc)
 
 	// Add styles directly into document
 	for _, s := range []*css.CSSStyleSheet{ss, imageSS} {
@@ -102,13 +236,31 @@ func cleanPage(doc *goquery.Document, ss *css.CSSStyleSheet) {
 	}
 }
 
-func decomposePage(page book.Resource, b book.Book) (*goquery.Document, *css.CSSStyleSheet, []book.Resource, error) {
+// Reading systems pick hyphenation and dictionaries by each page's language,
+// which is the book's unless the page declares a valid language of its own
+func setPageLanguage(doc *goquery.Document, bookLang string) {
+	htmlSelection := doc.Find("html")
+	lang, err := cleanMetadata.NormaliseLanguage(htmlSelection.AttrOr("xml:lang", htmlSelection.AttrOr("lang", "")))
+	if err != nil {
+		lang = bookLang
+	}
+	if lang == "" {
+		return
+	}
+	htmlSelection.SetAttr("lang", lang)
+	htmlSelection.SetAttr("xml:lang", lang)
+}
+
+func decomposePage(page book.Resource, g *graph.Graph) (*goquery.Document, *css.CSSStyleSheet, []book.Resource, error) {
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page.Contents))
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	styleSelection := doc.Find("style")
+	// Styles inside SVG and MathML belong to them, so are left in place
+	styleSelection := doc.Find("style").FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return !cleanHTML.IsPreserved(s)
+	})
 	linkSelection := doc.Find("link[rel=stylesheet]")
 
 	mergedStyles := styleSelection.Text()
@@ -119,10 +271,10 @@ func decomposePage(page book.Resource, b book.Book) (*goquery.Document, *css.CSS
 			err = errors.New("link missing href attribute")
 			return ""
 		}
-		absPath := path.Clean(path.Join(path.Dir(page.Path), relPath))
-		r, e := b.GetResource(absPath)
-		if e != nil {
-			err = e
+		absPath := book.AbsPath(page.Path, relPath)
+		r, ok := g.ByPath(absPath)
+		if !ok {
+			err = errors.New("can't find resource at path " + absPath)
 			return ""
 		}
 		linkedResources = append(linkedResources, r)
@@ -147,6 +299,9 @@ func decomposePage(page book.Resource, b book.Book) (*goquery.Document, *css.CSS
 
 func extractInlineStyles(doc *goquery.Document, ss *css.CSSStyleSheet) {
 	doc.Find("[style]").Each(func(i int, s *goquery.Selection) {
+		if cleanHTML.IsPreserved(s) {
+			return
+		}
 		className := fmt.Sprintf("reprint_%s_%d", s.Nodes[0].Data, i)
 		s.AddClass(className)
 		cssText, _ := s.Attr("style")
@@ -189,6 +344,9 @@ func extractImageStyles(doc *goquery.Document, ss *css.CSSStyleSheet) *css.CSSSt
 	// TODO: comply with CSS specificity
 	imageSS := &css.CSSStyleSheet{}
 	doc.Find("img").Each(func(i int, s *goquery.Selection) {
+		if cleanHTML.IsPreserved(s) {
+			return
+		}
 		// Find styles where the selector matches the img element
 		var styles []*css.CSSStyleDeclaration
 		for _, rule := range ss.CssRuleList {
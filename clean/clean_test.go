@@ -2,6 +2,7 @@ package clean
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/PuerkitoBio/goquery"
@@ -9,7 +10,9 @@ import (
 	"github.com/vanng822/css"
 
 	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/book/graph"
 	cleanCSS "github.com/asavoy/reprint/clean/css"
+	cleanHTML "github.com/asavoy/reprint/clean/html"
 )
 
 func TestDecomposePage(t *testing.T) {
@@ -39,7 +42,7 @@ func TestDecomposePage(t *testing.T) {
 			page,
 		},
 	}
-	doc, ss, gotSSResources, err := decomposePage(page, b)
+	doc, ss, gotSSResources, err := decomposePage(page, graph.New(&b))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -81,6 +84,30 @@ h3 {
 	}
 }
 
+func TestDecomposePageLeavesSVGStyles(t *testing.T) {
+	page := book.Resource{
+		Path: "page.xhtml",
+		Contents: []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><style>p{ color: green; }</style></head><body>
+<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><style>.a{fill:red}</style><circle class="a" cx="5" cy="5" r="4"/></svg>
+</body></html>`),
+	}
+	doc, ss, _, err := decomposePage(page, graph.New(&book.Book{Resources: []book.Resource{page}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanPage(doc, ss, nil)
+	got, _ := doc.Find("body").Html()
+	want := `
+<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><style>.a{fill:red}</style><circle class="a" cx="5" cy="5" r="4"></circle></svg>
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if strings.Contains(cleanCSS.Render(ss), "fill") {
+		t.Error("svg styles were merged into the page styles")
+	}
+}
+
 func TestExtractInlineStyles(t *testing.T) {
 	h := `
 <html>
@@ -174,3 +201,237 @@ body {
 		t.Error("css got != want:\n", diff)
 	}
 }
+
+func TestSetPageLanguage(t *testing.T) {
+	tests := []struct {
+		h    string
+		want string
+	}{
+		{
+			h:    `<html><body></body></html>`,
+			want: `<html lang="en" xml:lang="en"><head></head><body></body></html>`,
+		},
+		{
+			h:    `<html xml:lang="English"><body></body></html>`,
+			want: `<html xml:lang="en" lang="en"><head></head><body></body></html>`,
+		},
+		{
+			h:    `<html lang="fr"><body></body></html>`,
+			want: `<html lang="fr" xml:lang="fr"><head></head><body></body></html>`,
+		},
+	}
+	for _, test := range tests {
+		doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(test.h)))
+		setPageLanguage(doc, "en")
+		got, _ := doc.Html()
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Error("got != want:\n", diff)
+		}
+	}
+}
+
+func TestLinkedIDs(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{Path: "text/chapter 1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body>
+<p>Text<a href="../notes/notes.xhtml#fn1" id="ref1">1</a> <a href="#top">Top</a> <a href="http://example.com/#x">Web</a></p>
+</body></html>`)},
+			{Path: "notes/notes.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body>
+<p id="fn1"><a href="../text/chapter%201.xhtml#ref1">1</a> A note</p>
+</body></html>`)},
+		},
+		TOCItems:    []book.TOCItem{{Href: "text/chapter 1.xhtml", Children: []book.TOCItem{{Href: "text/chapter 1.xhtml#sec"}}}},
+		PageTargets: []book.PageTarget{{Href: "text/chapter 1.xhtml#page1"}},
+	}
+	got, err := linkedIDs(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]cleanHTML.ProtectedIDs{
+		"text/chapter 1.xhtml": {"sec": true, "page1": true, "top": true, "ref1": true},
+		"notes/notes.xhtml":    {"fn1": true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestLinkedIDsFromMediaOverlays(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{Path: "Text/page1.xhtml", MediaType: "application/xhtml+xml", MediaOverlay: "smil1", Contents: []byte(`<html><body><p><span id="w1">Once</span> <span id="w2">upon</span></p></body></html>`)},
+			{Path: "Audio/page1.smil", MediaType: "application/smil+xml", Contents: []byte(`<smil xmlns="http://www.w3.org/ns/SMIL" version="3.0"><body>
+<par><text src="../Text/page1.xhtml#w1"/><audio src="page1.mp3" clipBegin="0s" clipEnd="0.4s"/></par>
+<par><text src="../Text/page1.xhtml#w2"/><audio src="page1.mp3" clipBegin="0.4s" clipEnd="0.8s"/></par>
+</body></smil>`)},
+		},
+	}
+	got, err := linkedIDs(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]cleanHTML.ProtectedIDs{
+		"Text/page1.xhtml": {"w1": true, "w2": true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestIsFixedLayout(t *testing.T) {
+	page := func(ID string, viewport string) book.Resource {
+		return book.Resource{ID: ID, Path: ID + ".xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><head>` + viewport + `</head><body><img src="p.jpg"/></body></html>`)}
+	}
+	sized := `<meta name="viewport" content="width=1024, height=768"/>`
+	tests := []struct {
+		name string
+		b    book.Book
+		want bool
+	}{
+		{"pre-paginated", book.Book{Rendition: book.Rendition{Layout: book.LayoutPrePaginated}}, true},
+		{"viewports", book.Book{
+			Resources:  []book.Resource{page("p1", sized), page("p2", `<meta name="viewport" content="height = 768,width = 1024"/>`)},
+			SpineItems: []book.SpineItem{{ID: "p1"}, {ID: "p2"}},
+		}, true},
+		{"some viewports", book.Book{
+			Resources:  []book.Resource{page("p1", sized), page("p2", "")},
+			SpineItems: []book.SpineItem{{ID: "p1"}, {ID: "p2"}},
+		}, false},
+		{"device width", book.Book{
+			Resources:  []book.Resource{page("p1", `<meta name="viewport" content="width=device-width"/>`)},
+			SpineItems: []book.SpineItem{{ID: "p1"}},
+		}, false},
+		{"reflowable", book.Book{
+			Resources:  []book.Resource{page("p1", sized)},
+			SpineItems: []book.SpineItem{{ID: "p1"}},
+			Rendition:  book.Rendition{Layout: book.LayoutReflowable},
+		}, false},
+		{"no pages", book.Book{}, false},
+	}
+	for _, test := range tests {
+		if got := isFixedLayout(test.b); got != test.want {
+			t.Errorf("%s: isFixedLayout = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCleanFixedLayout(t *testing.T) {
+	contents := `<html xmlns="http://www.w3.org/1999/xhtml"><head><link href="fxl.css" rel="stylesheet" type="text/css"/></head><body><h1 style="position: absolute; left: 10px; top: 0">Picture Book</h1><div style="position: absolute; left: 10px; top: 20px; width: 300px"><span></span><img src="p.jpg"/></div></body></html>`
+	b := book.Book{
+		Metadata: book.Metadata{Titles: []book.Title{{Value: "Picture Book"}}, Language: "en"},
+		Resources: []book.Resource{
+			{ID: "p1", Path: "p1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(contents)},
+			{ID: "css", Path: "fxl.css", MediaType: "text/css", Contents: []byte(`img { width: 300px; height: 400px; }`)},
+			{ID: "img", Path: "p.jpg", MediaType: "image/jpeg", Contents: []byte("\xff\xd8\xff")},
+		},
+		SpineItems: []book.SpineItem{{ID: "p1", Linear: true}},
+		TOCItems:   []book.TOCItem{{Label: "Start", Href: "p1.xhtml"}},
+		Rendition:  book.Rendition{Layout: book.LayoutPrePaginated},
+	}
+	if _, err := Clean(&b); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(contents, string(b.Resources[0].Contents)); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff(3, len(b.Resources)); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestCleanFixedLayoutPages(t *testing.T) {
+	reflowable := `<html xmlns="http://www.w3.org/1999/xhtml"><head><link href="book.css" rel="stylesheet" type="text/css"/></head><body><h1>Chapter 1</h1><p>Text</p></body></html>`
+	fixed := `<html xmlns="http://www.w3.org/1999/xhtml"><head><link href="book.css" rel="stylesheet" type="text/css"/></head><body><div style="position: absolute; left: 10px; top: 20px"><span></span><img src="p.jpg"/></div></body></html>`
+	b := book.Book{
+		Metadata: book.Metadata{Titles: []book.Title{{Value: "Mixed Book"}}, Language: "en"},
+		Resources: []book.Resource{
+			{ID: "c1", Path: "c1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(reflowable)},
+			{ID: "p1", Path: "p1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(fixed)},
+			{ID: "css", Path: "book.css", MediaType: "text/css", Contents: []byte(`img { width: 300px; }`)},
+			{ID: "img", Path: "p.jpg", MediaType: "image/jpeg", Contents: []byte("\xff\xd8\xff")},
+		},
+		SpineItems: []book.SpineItem{
+			{ID: "c1", Linear: true},
+			{ID: "p1", Linear: true, Properties: []string{"rendition:layout-pre-paginated"}},
+		},
+		TOCItems: []book.TOCItem{{Label: "Chapter 1", Href: "c1.xhtml"}, {Label: "Plate", Href: "p1.xhtml"}},
+	}
+	if _, err := Clean(&b); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, r := range b.Resources {
+		got[r.Path] = string(r.Contents)
+	}
+	if diff := cmp.Diff(fixed, got["p1.xhtml"]); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if _, ok := got["book.css"]; !ok {
+		t.Error("the fixed layout page's stylesheet was removed")
+	}
+	if strings.Contains(got["c1.xhtml"], "<link") {
+		t.Error("the reflowable page wasn't cleaned:\n", got["c1.xhtml"])
+	}
+}
+
+func TestCleanVerticalWriting(t *testing.T) {
+	b := book.Book{
+		Metadata: book.Metadata{Titles: []book.Title{{Value: "縦書き"}}, Language: "ja"},
+		Resources: []book.Resource{
+			{ID: "p1", Path: "p1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><head><link href="v.css" rel="stylesheet" type="text/css"/></head><body><p>本文</p></body></html>`)},
+			{ID: "css", Path: "v.css", MediaType: "text/css", Contents: []byte(`html { -epub-writing-mode: vertical-rl; writing-mode: vertical-rl; margin: 0; }`)},
+		},
+		SpineItems: []book.SpineItem{{ID: "p1", Linear: true, Properties: []string{"page-spread-right"}}},
+		TOCItems:   []book.TOCItem{{Label: "本文", Href: "p1.xhtml"}},
+		Rendition:  book.Rendition{PageProgression: "rtl"},
+	}
+	if _, err := Clean(&b); err != nil {
+		t.Fatal(err)
+	}
+	got := string(b.Resources[0].Contents)
+	for _, want := range []string{"-epub-writing-mode: vertical-rl;", "writing-mode: vertical-rl;"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("page is missing %q:\n%s", want, got)
+		}
+	}
+	if diff := cmp.Diff([]book.SpineItem{{ID: "p1", Linear: true, Properties: []string{"page-spread-right"}}}, b.SpineItems); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff("rtl", b.Rendition.PageProgression); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestCleanPagePreservesSVG(t *testing.T) {
+	h := `<html xmlns="http://www.w3.org/1999/xhtml"><head></head><body>
+<p>Figure</p>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10"><circle cx="5" cy="5" r="4" style="fill: url(#g); stroke: black"/><image xlink:href="a.png" width="10" height="10"/><foreignObject><div xmlns="http://www.w3.org/1999/xhtml"><span></span></div></foreignObject></svg>
+</body></html>`
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(cleanHTML.ConvertXHTMLToHTML(h))))
+	cleanPage(doc, &css.CSSStyleSheet{}, nil)
+	got, _ := doc.Find("body").Html()
+	want := `
+<p>Figure</p>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10"><circle cx="5" cy="5" r="4" style="fill: url(#g); stroke: black"></circle><image xlink:href="a.png" width="10" height="10"></image><foreignObject><div xmlns="http://www.w3.org/1999/xhtml"><span></span></div></foreignObject></svg>
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestCleanPagePreservesMathML(t *testing.T) {
+	h := `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:m="http://www.w3.org/1998/Math/MathML"><head></head><body>
+<p><math xmlns="http://www.w3.org/1998/Math/MathML" alttext="x squared" display="block"><msup><mi style="color: red">x</mi><mn>2</mn></msup><mspace width="1em"/></math></p>
+<p><m:math><m:mi>a</m:mi><m:mspace width="1em"/></m:math></p>
+</body></html>`
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(cleanHTML.ConvertXHTMLToHTML(h))))
+	cleanPage(doc, &css.CSSStyleSheet{}, nil)
+	got, _ := doc.Find("body").Html()
+	want := `
+<p><math xmlns="http://www.w3.org/1998/Math/MathML" alttext="x squared" display="block"><msup><mi style="color: red">x</mi><mn>2</mn></msup><mspace width="1em"></mspace></math></p>
+<p><m:math alttext="a"><m:mi>a</m:mi><m:mspace width="1em"></m:mspace></m:math></p>
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
@@ -131,6 +131,32 @@ func simpleStyles(rule *css.CSSRule) {
 		"text-align":       true,
 		"text-transform":   true,
 		"white-space":      true,
+		// Right-to-left and vertical text read wrongly without these,
+		"direction":                true,
+		"unicode-bidi":             true,
+		"writing-mode":             true,
+		"-epub-writing-mode":       true,
+		"-webkit-writing-mode":     true,
+		"text-orientation":         true,
+		"-epub-text-orientation":   true,
+		"-webkit-text-orientation": true,
+		"text-combine-upright":     true,
+		"-epub-text-combine":       true,
+		"-webkit-text-combine":     true,
+		// As are ruby annotations and emphasis marks
+		"ruby-align":                   true,
+		"ruby-position":                true,
+		"-epub-ruby-position":          true,
+		"-webkit-ruby-position":        true,
+		"text-emphasis":                true,
+		"text-emphasis-color":          true,
+		"text-emphasis-position":       true,
+		"text-emphasis-style":          true,
+		"-epub-text-emphasis":          true,
+		"-epub-text-emphasis-position": true,
+		"-epub-text-emphasis-style":    true,
+		"-webkit-text-emphasis":        true,
+		"-webkit-text-emphasis-style":  true,
 	}
 	for _, childRule := range rule.Rules {
 		simpleStyles(childRule)
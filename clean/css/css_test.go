@@ -106,6 +106,14 @@ blockquote.code {
 .squeeze-amzn {
     display: none;
 }
+html {
+    -epub-writing-mode: vertical-rl;
+    writing-mode: vertical-rl;
+    margin: 0;
+}
+.tcy {
+    text-combine-upright: all;
+}
 `)
 	KeepSimpleStyles(ss)
 	got := Render(ss)
@@ -118,6 +126,13 @@ blockquote.code {
 .squeeze-amzn {
     display: none;
 }
+html {
+    -epub-writing-mode: vertical-rl;
+    writing-mode: vertical-rl;
+}
+.tcy {
+    text-combine-upright: all;
+}
 `
 
 	if diff := cmp.Diff(want, got); diff != "" {
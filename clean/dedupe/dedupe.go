@@ -0,0 +1,69 @@
+package dedupe
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/book/graph"
+)
+
+// Merge resources with identical contents into the first of them, rewriting
+// every reference to the others. Returns descriptions of the merges.
+//
+// Pages in the spine are never merged, as each is a place in the reading
+// order. Nor are resources whose identical relative references would lead
+// to different places from different directories.
+func Dedupe(b *book.Book) []string {
+	var merges []string
+	g := graph.New(b)
+	inSpine := map[string]bool{}
+	for _, spineItem := range b.SpineItems {
+		inSpine[spineItem.ID] = true
+	}
+
+	type key struct {
+		mediaType string
+		hash      [sha256.Size]byte
+	}
+	firstByKey := map[key][]string{}
+	var duplicates [][2]string
+	for _, r := range b.Resources {
+		if inSpine[r.ID] {
+			continue
+		}
+		k := key{r.MediaType, sha256.Sum256(r.Contents)}
+		merged := false
+		for _, firstPath := range firstByKey[k] {
+			if sameTargets(g, firstPath, r.Path) {
+				duplicates = append(duplicates, [2]string{r.Path, firstPath})
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			firstByKey[k] = append(firstByKey[k], r.Path)
+		}
+	}
+
+	for _, duplicate := range duplicates {
+		// Can't fail, as both paths were just found in the book
+		_ = g.Merge(duplicate[0], duplicate[1])
+		merges = append(merges, fmt.Sprintf("merged %s into identical %s", duplicate[0], duplicate[1]))
+	}
+	return merges
+}
+
+// Whether two resources refer to the same resources
+func sameTargets(g *graph.Graph, a string, b string) bool {
+	aRefs, bRefs := g.References(a), g.References(b)
+	if len(aRefs) != len(bRefs) {
+		return false
+	}
+	for i := range aRefs {
+		if aRefs[i].To != bRefs[i].To {
+			return false
+		}
+	}
+	return true
+}
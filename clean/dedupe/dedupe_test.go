@@ -0,0 +1,58 @@
+package dedupe
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+)
+
+func TestDedupe(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{ID: "ch1", Path: "ch1/page.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><head><link href="style.css" rel="stylesheet" type="text/css"/></head><body><img src="logo.png"/></body></html>`)},
+			{ID: "ch2", Path: "ch2/page.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><head><link href="style.css" rel="stylesheet" type="text/css"/></head><body><img src="logo.png"/></body></html>`)},
+			{ID: "css1", Path: "ch1/style.css", MediaType: "text/css", Contents: []byte(`p { margin: 0; }`)},
+			{ID: "css2", Path: "ch2/style.css", MediaType: "text/css", Contents: []byte(`p { margin: 0; }`)},
+			{ID: "logo1", Path: "ch1/logo.png", MediaType: "image/png", Contents: []byte("PNG")},
+			{ID: "logo2", Path: "ch2/logo.png", MediaType: "image/png", Contents: []byte("PNG")},
+			{ID: "cover", Path: "cover.png", MediaType: "image/png", Contents: []byte("PNG")},
+			// Identical, but the url() leads to different images
+			{ID: "bg1", Path: "ch1/bg.css", MediaType: "text/css", Contents: []byte(`body { background: url(logo.png); }`)},
+			{ID: "bg2", Path: "other/bg.css", MediaType: "text/css", Contents: []byte(`body { background: url(logo.png); }`)},
+			{ID: "logo3", Path: "other/logo.png", MediaType: "image/png", Contents: []byte("GIF")},
+		},
+		SpineItems:   []book.SpineItem{{ID: "ch1", Linear: true}, {ID: "ch2", Linear: true}},
+		CoverImageID: "cover",
+		TOCItems:     []book.TOCItem{{Label: "Logo", Href: "ch2/logo.png"}},
+	}
+	gotMerges := Dedupe(&b)
+	wantMerges := []string{
+		"merged ch2/style.css into identical ch1/style.css",
+		"merged ch2/logo.png into identical ch1/logo.png",
+		"merged cover.png into identical ch1/logo.png",
+	}
+	if diff := cmp.Diff(wantMerges, gotMerges); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+
+	var gotPaths []string
+	for _, r := range b.Resources {
+		gotPaths = append(gotPaths, r.Path)
+	}
+	wantPaths := []string{"ch1/page.xhtml", "ch2/page.xhtml", "ch1/style.css", "ch1/logo.png", "ch1/bg.css", "other/bg.css", "other/logo.png"}
+	if diff := cmp.Diff(wantPaths, gotPaths); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	wantPage := `<html><head><link href="../ch1/style.css" rel="stylesheet" type="text/css"/></head><body><img src="../ch1/logo.png"/></body></html>`
+	if diff := cmp.Diff(wantPage, string(b.Resources[1].Contents)); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff("logo1", b.CoverImageID); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff("ch1/logo.png", b.TOCItems[0].Href); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
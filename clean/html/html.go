@@ -9,7 +9,11 @@ import (
 	"golang.org/x/net/html/atom"
 )
 
-var selfClosingElementRegex = regexp.MustCompile(`<(\w+)(\b[^>]*?)?/>`)
+// Including prefixed and hyphenated names, like <m:mspace/> in MathML
+var selfClosingElementRegex = regexp.MustCompile(`<([\w:-]+)(\b[^>]*?)?/>`)
+
+// IDs of a page that links point to, which must survive cleaning
+type ProtectedIDs map[string]bool
 
 // Golang's HTML parser doesn't parse XHTML. Most of the time XHTML is stricter
 // for our purposes, except for handling of self-closing elements, where HTML 5
@@ -19,18 +23,88 @@ func ConvertXHTMLToHTML(html string) string {
 	return selfClosingElementRegex.ReplaceAllString(html, "<$1$2></$1>")
 }
 
-func RemoveEmptySpans(doc *goquery.Document) {
+// Elements whose contents are left exactly as they are. Ruby annotations are
+// placed character by character against their base text, and MathML and
+// SVG aren't HTML at all, though they're parsed as such.
+var preservedElements = map[string]bool{
+	"ruby": true,
+	"math": true,
+	"svg":  true,
+}
+
+// Whether an element is or is inside a preserved element
+func IsPreserved(s *goquery.Selection) bool {
+	for n := s.Nodes[0]; n != nil; n = n.Parent {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		// Prefixed names like m:math are parsed as they are
+		name := n.Data
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			name = name[i+1:]
+		}
+		if preservedElements[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// Fill in a missing alttext on MathML from its text, for reading systems
+// that can't render MathML
+func AddMathAltText(doc *goquery.Document) {
+	doc.Find("*").Each(func(_ int, s *goquery.Selection) {
+		name := s.Nodes[0].Data
+		if name != "math" && !strings.HasSuffix(name, ":math") {
+			return
+		}
+		if _, ok := s.Attr("alttext"); ok {
+			return
+		}
+		// Tokens like <mi> and <mn> are separate words
+		var words []string
+		var walk func(n *html.Node)
+		walk = func(n *html.Node) {
+			if n.Type == html.TextNode {
+				words = append(words, strings.Fields(n.Data)...)
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+		}
+		walk(s.Nodes[0])
+		if len(words) > 0 {
+			s.SetAttr("alttext", strings.Join(words, " "))
+		}
+	})
+}
+
+func RemoveEmptySpans(doc *goquery.Document, protected ProtectedIDs) {
 	doc.Find("span").Each(func(_ int, s *goquery.Selection) {
+		if IsPreserved(s) {
+			return
+		}
 		elementCount := s.Children().Length()
 		text := strings.TrimSpace(s.Text())
-		if elementCount == 0 && text == "" {
+		if elementCount == 0 && text == "" && !isPageBreak(s) {
+			moveIDs(s, protected)
 			s.Remove()
 		}
 	})
 }
 
+// Page break markers are empty, but locate the pages of the print edition
+func isPageBreak(s *goquery.Selection) bool {
+	epubType := s.AttrOr("epub:type", "")
+	role := s.AttrOr("role", "")
+	return strings.Contains(epubType, "pagebreak") || role == "doc-pagebreak"
+}
+
 func RemoveEmptyDivs(doc *goquery.Document) {
 	doc.Find("div").Each(func(_ int, s *goquery.Selection) {
+		if IsPreserved(s) {
+			return
+		}
 		elementCount := s.Children().Length()
 		text := strings.TrimSpace(s.Text())
 		if elementCount == 0 && text == "" {
@@ -45,8 +119,11 @@ func RemoveEmptyDivs(doc *goquery.Document) {
 	})
 }
 
-func RemoveLineBreaks(doc *goquery.Document) {
+func RemoveLineBreaks(doc *goquery.Document, protected ProtectedIDs) {
 	doc.Find("p > br, li > br").Each(func(_ int, s *goquery.Selection) {
+		if IsPreserved(s) {
+			return
+		}
 		parent := s.Parent()
 		text := strings.TrimSpace(parent.Text())
 
@@ -54,6 +131,7 @@ func RemoveLineBreaks(doc *goquery.Document) {
 		styleElements := siblingElements.Filter("b, em, i, span, strong, u")
 
 		if text == "" && siblingElements.Length() == styleElements.Length() {
+			moveIDs(parent, protected)
 			parent.Remove()
 		}
 	})
@@ -65,7 +143,8 @@ func RemoveContainers(doc *goquery.Document) {
 	for {
 		childElements := body.Children().Filter("*:not(a[id])")
 		// Detect elements that container the body contents
-		if childElements.Length() == 1 && childElements.Filter("div, blockquote").Length() == 1 {
+		// Containers that set the text direction are kept, as it would be lost
+		if childElements.Length() == 1 && childElements.Filter("div, blockquote").Not("[dir]").Length() == 1 {
 			// Replace with container's child nodes
 			childElements.Each(func(_ int, s *goquery.Selection) {
 				node := s.Nodes[0]
@@ -86,9 +165,13 @@ func RemoveContainers(doc *goquery.Document) {
 	}
 }
 
-func RemoveBoldInHeadings(doc *goquery.Document) {
+func RemoveBoldInHeadings(doc *goquery.Document, protected ProtectedIDs) {
 	doc.Find("h1, h2, h3, h4, h5, h6").Each(func(_ int, s *goquery.Selection) {
 		s.Find("b, strong").Each(func(_ int, s2 *goquery.Selection) {
+			if IsPreserved(s2) {
+				return
+			}
+			moveIDToChild(s2, protected)
 			node := s2.Nodes[0]
 			// To capture element and text nodes
 			var childNodes []*html.Node
@@ -100,9 +183,13 @@ func RemoveBoldInHeadings(doc *goquery.Document) {
 	})
 }
 
-func RemoveExcessBlockquotes(doc *goquery.Document) {
+func RemoveExcessBlockquotes(doc *goquery.Document, protected ProtectedIDs) {
 	doc.Find("h1, h2, h3, h4, h5, h6, li").Each(func(_ int, s *goquery.Selection) {
 		s.Find("blockquote").Each(func(_ int, s2 *goquery.Selection) {
+			if IsPreserved(s2) {
+				return
+			}
+			moveIDToChild(s2, protected)
 			node := s2.Nodes[0]
 			// To capture element and text nodes
 			var childNodes []*html.Node
@@ -114,6 +201,46 @@ func RemoveExcessBlockquotes(doc *goquery.Document) {
 	})
 }
 
+// Move the protected IDs of an element that's about to be removed, and of its
+// descendants, onto a neighbouring element without an ID, or onto anchors in
+// its place
+func moveIDs(s *goquery.Selection, protected ProtectedIDs) {
+	var IDs []string
+	s.Find("[id]").AddBack().Each(func(_ int, withID *goquery.Selection) {
+		if ID := withID.AttrOr("id", ""); protected[ID] {
+			IDs = append(IDs, ID)
+		}
+	})
+	if len(IDs) == 0 {
+		return
+	}
+	for _, neighbour := range []*goquery.Selection{s.Next(), s.Prev()} {
+		if neighbour.Length() == 1 && !neighbour.Is("[id]") {
+			neighbour.SetAttr("id", IDs[0])
+			IDs = IDs[1:]
+			break
+		}
+	}
+	for _, ID := range IDs {
+		s.BeforeNodes(anchorNode(ID))
+	}
+}
+
+// Move the protected ID of an element that's about to be unwrapped onto its
+// first child element, or onto an anchor in its place
+func moveIDToChild(s *goquery.Selection, protected ProtectedIDs) {
+	ID := s.AttrOr("id", "")
+	if !protected[ID] {
+		return
+	}
+	child := s.Children().First()
+	if child.Length() == 1 && !child.Is("[id]") {
+		child.SetAttr("id", ID)
+	} else {
+		s.BeforeNodes(anchorNode(ID))
+	}
+}
+
 func anchorNode(ID string) *html.Node {
 	return &html.Node{
 		Type:     html.ElementNode,
@@ -39,6 +39,51 @@ func TestConvertXHTMLToHTML(t *testing.T) {
 	}
 }
 
+func TestConvertPrefixedXHTMLToHTML(t *testing.T) {
+	got := ConvertXHTMLToHTML(`<m:math><m:mi>x</m:mi><m:mspace width="1em"/><annotation-xml/></m:math>`)
+	want := `<m:math><m:mi>x</m:mi><m:mspace width="1em"></m:mspace><annotation-xml></annotation-xml></m:math>`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestPreserveRuby(t *testing.T) {
+	h := `<html><head></head><body>
+<p><ruby><rb>東</rb><rp><span>(</span></rp><rt><span class="kana"></span>とう</rt><rp>)</rp></ruby></p>
+</body></html>`
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(ConvertXHTMLToHTML(h))))
+	RemoveEmptySpans(doc, nil)
+	RemoveLineBreaks(doc, nil)
+	got, _ := doc.Html()
+	want := `<html><head></head><body>
+<p><ruby><rb>東</rb><rp><span>(</span></rp><rt><span class="kana"></span>とう</rt><rp>)</rp></ruby></p>
+</body></html>`
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestAddMathAltText(t *testing.T) {
+	h := `<html><head></head><body>
+<p><math><msup><mi>x</mi><mn>2</mn></msup></math></p>
+<p><m:math alttext="a squared"><m:msup><m:mi>a</m:mi><m:mn>2</m:mn></m:msup></m:math></p>
+<p><m:math><m:mi>b</m:mi></m:math></p>
+</body></html>`
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(h)))
+	AddMathAltText(doc)
+	got, _ := doc.Html()
+	want := `<html><head></head><body>
+<p><math alttext="x 2"><msup><mi>x</mi><mn>2</mn></msup></math></p>
+<p><m:math alttext="a squared"><m:msup><m:mi>a</m:mi><m:mn>2</m:mn></m:msup></m:math></p>
+<p><m:math alttext="b"><m:mi>b</m:mi></m:math></p>
+</body></html>`
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
 func TestRemoveEmptySpans(t *testing.T) {
 	h := `
 <html>
@@ -49,7 +94,7 @@ func TestRemoveEmptySpans(t *testing.T) {
 </html>
 `
 	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(h)))
-	RemoveEmptySpans(doc)
+	RemoveEmptySpans(doc, nil)
 	got, _ := doc.Html()
 	want := `<html><head></head><body>
 <p></p>
@@ -107,7 +152,7 @@ func TestRemoveLineBreaks(t *testing.T) {
 </html>
 `
 	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(h)))
-	RemoveLineBreaks(doc)
+	RemoveLineBreaks(doc, nil)
 	got, _ := doc.Html()
 	want := `<html><head></head><body>
 <h1>Heading</h1>
@@ -145,6 +190,28 @@ func TestRemoveContainers(t *testing.T) {
 <p>This is a paragraph.</p>
 
 
+</body></html>`
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestRemoveContainersWithDirection(t *testing.T) {
+	h := `
+<html>
+<body>
+<div dir="rtl"><p>فقرة</p></div>
+</body>
+</html>
+`
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(h)))
+	RemoveContainers(doc)
+	got, _ := doc.Html()
+	want := `<html><head></head><body>
+<div dir="rtl"><p>فقرة</p></div>
+
+
 </body></html>`
 
 	if diff := cmp.Diff(want, got); diff != "" {
@@ -163,7 +230,7 @@ func TestRemoveBoldnHeadings(t *testing.T) {
 </html>
 `
 	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(h)))
-	RemoveBoldInHeadings(doc)
+	RemoveBoldInHeadings(doc, nil)
 	got, _ := doc.Html()
 	want := `<html><head></head><body>
 <h1>One</h1>
@@ -191,7 +258,7 @@ func TestRemoveExcessBlockquotes(t *testing.T) {
 </html>
 `
 	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(h)))
-	RemoveExcessBlockquotes(doc)
+	RemoveExcessBlockquotes(doc, nil)
 	got, _ := doc.Html()
 	want := `<html><head></head><body>
 <h1>One</h1>
@@ -202,6 +269,39 @@ func TestRemoveExcessBlockquotes(t *testing.T) {
 </ul>
 
 
+</body></html>`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestProtectedIDs(t *testing.T) {
+	h := `
+<html>
+<body>
+<p>Text<a href="#fn1" id="ref1">1</a><span id="page1"></span> more<span id="unused"></span></p>
+<p><span id="a"></span><br/></p>
+<p>After</p>
+<h1><b id="title">Title</b></h1>
+<ul><li><blockquote id="quote">Item</blockquote></li></ul>
+</body>
+</html>
+`
+	protected := ProtectedIDs{"page1": true, "a": true, "title": true, "quote": true}
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader([]byte(h)))
+	RemoveEmptySpans(doc, protected)
+	RemoveLineBreaks(doc, protected)
+	RemoveBoldInHeadings(doc, protected)
+	RemoveExcessBlockquotes(doc, protected)
+	got, _ := doc.Html()
+	want := `<html><head></head><body>
+<p>Text<a href="#fn1" id="ref1">1</a><a id="page1"></a> more</p>
+
+<p id="a">After</p>
+<h1><a id="title"></a>Title</h1>
+<ul><li><a id="quote"></a>Item</li></ul>
+
+
 </body></html>`
 	if diff := cmp.Diff(want, got); diff != "" {
 		t.Error("got != want:\n", diff)
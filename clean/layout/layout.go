@@ -0,0 +1,171 @@
+package layout
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/book/graph"
+)
+
+// A resource moving from one path to another
+type Move struct {
+	From string
+	To   string
+}
+
+func (m Move) String() string {
+	return fmt.Sprintf("%s -> %s", m.From, m.To)
+}
+
+// Folder of the normalised layout for a media type
+func folder(mediaType string) string {
+	switch {
+	case mediaType == "application/xhtml+xml" || mediaType == "text/html":
+		return "Text"
+	case mediaType == "text/css":
+		return "Styles"
+	case strings.HasPrefix(mediaType, "image/"):
+		return "Images"
+	case strings.HasPrefix(mediaType, "font/"),
+		strings.Contains(mediaType, "font"),
+		mediaType == "application/vnd.ms-opentype":
+		return "Fonts"
+	case strings.HasPrefix(mediaType, "audio/"):
+		return "Audio"
+	case strings.HasPrefix(mediaType, "video/"):
+		return "Video"
+	}
+	return "Misc"
+}
+
+// Where each resource goes in the normalised layout, leaving out resources
+// that are already in place
+func Plan(b book.Book) []Move {
+	type target struct {
+		dir, name, ext string
+	}
+	targets := make([]target, len(b.Resources))
+	// Case-insensitive, as some readers and file systems are
+	taken := map[string]bool{}
+	for i, r := range b.Resources {
+		t := target{dir: folder(r.MediaType), ext: strings.ToLower(path.Ext(r.Path))}
+		switch t.dir {
+		case "Text":
+			t.ext = ".xhtml"
+		case "Styles":
+			t.ext = ".css"
+		}
+		t.name = safeName(strings.TrimSuffix(path.Base(r.Path), path.Ext(r.Path)))
+		if t.name == "" {
+			t.name = safeName(r.ID)
+		}
+		if t.name == "" {
+			t.name = "resource"
+		}
+		targets[i] = t
+		if path.Join(t.dir, t.name+t.ext) == r.Path {
+			taken[strings.ToLower(r.Path)] = true
+		}
+	}
+
+	var moves []Move
+	for i, r := range b.Resources {
+		t := targets[i]
+		to := path.Join(t.dir, t.name+t.ext)
+		if to == r.Path {
+			continue
+		}
+		for n := 2; taken[strings.ToLower(to)]; n++ {
+			to = path.Join(t.dir, fmt.Sprintf("%s-%d%s", t.name, n, t.ext))
+		}
+		taken[strings.ToLower(to)] = true
+		moves = append(moves, Move{From: r.Path, To: to})
+	}
+	return moves
+}
+
+// Move resources into the normalised layout, rewriting every reference to
+// them. Returns the moves made.
+func Normalise(b *book.Book) ([]Move, error) {
+	moves := Plan(*b)
+	g := graph.New(b)
+	pending := make([]Move, len(moves))
+	copy(pending, moves)
+	for len(pending) > 0 {
+		var blocked []Move
+		for _, move := range pending {
+			if _, ok := g.ByPath(move.To); ok {
+				// Another resource is still there, waiting for its own move
+				blocked = append(blocked, move)
+				continue
+			}
+			if err := g.Rename(move.From, move.To); err != nil {
+				return nil, err
+			}
+		}
+		if len(blocked) == len(pending) {
+			// Resources swapping places, so one goes aside first
+			aside := blocked[0].To + ".reprint-tmp"
+			if err := g.Rename(blocked[0].From, aside); err != nil {
+				return nil, err
+			}
+			blocked[0].From = aside
+		}
+		pending = blocked
+	}
+	return moves, nil
+}
+
+// Letters with diacritics, by the ASCII letters they're written without
+var asciiLetters = map[rune]string{}
+
+func init() {
+	for ascii, letters := range map[string]string{
+		"a": "àáâãäåāăą", "A": "ÀÁÂÃÄÅĀĂĄ", "ae": "æ", "AE": "Æ",
+		"c": "çćĉċč", "C": "ÇĆĈĊČ", "d": "ďđð", "D": "ĎĐÐ",
+		"e": "èéêëēĕėęě", "E": "ÈÉÊËĒĔĖĘĚ", "g": "ĝğġģ", "G": "ĜĞĠĢ",
+		"h": "ĥħ", "H": "ĤĦ", "i": "ìíîïĩīĭįı", "I": "ÌÍÎÏĨĪĬĮİ",
+		"j": "ĵ", "J": "Ĵ", "k": "ķ", "K": "Ķ", "l": "ĺļľŀł", "L": "ĹĻĽĿŁ",
+		"n": "ñńņňŉ", "N": "ÑŃŅŇ", "o": "òóôõöøōŏő", "O": "ÒÓÔÕÖØŌŎŐ",
+		"oe": "œ", "OE": "Œ", "r": "ŕŗř", "R": "ŔŖŘ", "s": "śŝşš", "S": "ŚŜŞŠ",
+		"ss": "ß", "t": "ţťŧ", "T": "ŢŤŦ", "th": "þ", "TH": "Þ",
+		"u": "ùúûüũūŭůűų", "U": "ÙÚÛÜŨŪŬŮŰŲ", "w": "ŵ", "W": "Ŵ",
+		"y": "ýÿŷ", "Y": "ÝŸŶ", "z": "źżž", "Z": "ŹŻŽ",
+	} {
+		for _, letter := range letters {
+			asciiLetters[letter] = ascii
+		}
+	}
+}
+
+// A file name that needs no URL encoding: ASCII letters, digits, "-" and "_",
+// with other characters dropped or replaced by "-"
+func safeName(name string) string {
+	var safe strings.Builder
+	dash := false
+	for _, r := range name {
+		var s string
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			s = string(r)
+		case asciiLetters[r] != "":
+			s = asciiLetters[r]
+		case r > 127 && r != '\u00a0':
+			// Letters of other scripts can't be written in ASCII
+			continue
+		default:
+			if safe.Len() > 0 {
+				dash = true
+			}
+			continue
+		}
+		if dash {
+			safe.WriteString("-")
+			dash = false
+		}
+		safe.WriteString(s)
+	}
+	return safe.String()
+}
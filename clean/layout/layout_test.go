@@ -0,0 +1,109 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+)
+
+func TestPlan(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{ID: "ch1", Path: "OEBPS/Content/Part 1/Chapter 1.html", MediaType: "application/xhtml+xml"},
+			{ID: "ch2", Path: "OEBPS/Content/Part 2/chapter1.xhtml", MediaType: "application/xhtml+xml"},
+			{ID: "css", Path: "OEBPS/css/Main Style.css", MediaType: "text/css"},
+			{ID: "img", Path: "OEBPS/Bilder/Über uns.JPG", MediaType: "image/jpeg"},
+			{ID: "font", Path: "OEBPS/fonts/serif.otf", MediaType: "application/vnd.ms-opentype"},
+			{ID: "done", Path: "Images/cover.png", MediaType: "image/png"},
+		},
+	}
+	got := Plan(b)
+	want := []Move{
+		{From: "OEBPS/Content/Part 1/Chapter 1.html", To: "Text/Chapter-1.xhtml"},
+		{From: "OEBPS/Content/Part 2/chapter1.xhtml", To: "Text/chapter1.xhtml"},
+		{From: "OEBPS/css/Main Style.css", To: "Styles/Main-Style.css"},
+		{From: "OEBPS/Bilder/Über uns.JPG", To: "Images/Uber-uns.jpg"},
+		{From: "OEBPS/fonts/serif.otf", To: "Fonts/serif.otf"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestPlanCollisions(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{ID: "a", Path: "a/ch.xhtml", MediaType: "application/xhtml+xml"},
+			{ID: "b", Path: "b/CH.xhtml", MediaType: "application/xhtml+xml"},
+			{ID: "chapter", Path: "第一章.xhtml", MediaType: "application/xhtml+xml"},
+			{ID: "c", Path: "Text/ch.xhtml", MediaType: "application/xhtml+xml"},
+		},
+	}
+	got := Plan(b)
+	want := []Move{
+		{From: "a/ch.xhtml", To: "Text/ch-2.xhtml"},
+		{From: "b/CH.xhtml", To: "Text/CH-3.xhtml"},
+		{From: "第一章.xhtml", To: "Text/chapter.xhtml"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestNormalise(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{ID: "ch1", Path: "OEBPS/Content/Part 1/Chapter 1.html", MediaType: "application/xhtml+xml", Contents: []byte(`<html><head>
+<link href="../../css/Main%20Style.css" rel="stylesheet" type="text/css"/>
+</head><body><p><img src="../../Bilder/Über%20uns.JPG"/> <a href="../Part%202/chapter1.xhtml#top">Next</a></p></body></html>`)},
+			{ID: "ch2", Path: "OEBPS/Content/Part 2/chapter1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><p id="top">Two</p></body></html>`)},
+			{ID: "css", Path: "OEBPS/css/Main Style.css", MediaType: "text/css", Contents: []byte(`@font-face { src: url("../fonts/serif.otf"); }`)},
+			{ID: "img", Path: "OEBPS/Bilder/Über uns.JPG", MediaType: "image/jpeg"},
+			{ID: "font", Path: "OEBPS/fonts/serif.otf", MediaType: "application/vnd.ms-opentype"},
+			{ID: "done", Path: "Images/cover.png", MediaType: "image/png"},
+		},
+		SpineItems: []book.SpineItem{{ID: "ch1", Linear: true}, {ID: "ch2", Linear: true}},
+		TOCItems:   []book.TOCItem{{Label: "Two", Href: "OEBPS/Content/Part 2/chapter1.xhtml#top"}},
+	}
+	if _, err := Normalise(&b); err != nil {
+		t.Fatal(err)
+	}
+	wantPage := `<html><head>
+<link href="../Styles/Main-Style.css" rel="stylesheet" type="text/css"/>
+</head><body><p><img src="../Images/Uber-uns.jpg"/> <a href="chapter1.xhtml#top">Next</a></p></body></html>`
+	if diff := cmp.Diff(wantPage, string(b.Resources[0].Contents)); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	wantCSS := `@font-face { src: url("../Fonts/serif.otf"); }`
+	if diff := cmp.Diff(wantCSS, string(b.Resources[2].Contents)); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff("Text/chapter1.xhtml#top", b.TOCItems[0].Href); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestNormaliseSwap(t *testing.T) {
+	// Each resource is where the other belongs
+	b := book.Book{
+		Resources: []book.Resource{
+			{ID: "page", Path: "Styles/b.css", MediaType: "application/xhtml+xml", Contents: []byte(`<html><head><link href="../Text/b.xhtml" rel="stylesheet" type="text/css"/></head></html>`)},
+			{ID: "css", Path: "Text/b.xhtml", MediaType: "text/css"},
+		},
+	}
+	if _, err := Normalise(&b); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("Text/b.xhtml", b.Resources[0].Path); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff("Styles/b.css", b.Resources[1].Path); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	wantPage := `<html><head><link href="../Styles/b.css" rel="stylesheet" type="text/css"/></head></html>`
+	if diff := cmp.Diff(wantPage, string(b.Resources[0].Contents)); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
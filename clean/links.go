@@ -0,0 +1,73 @@
+package clean
+
+import (
+	"bytes"
+	"net/url"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/book/graph"
+	cleanHTML "github.com/asavoy/reprint/clean/html"
+)
+
+// Find the IDs that links point to across the book, from the TOC, page list,
+// guide, links in pages and media overlays, by page path
+func linkedIDs(b book.Book) (map[string]cleanHTML.ProtectedIDs, error) {
+	protected := map[string]cleanHTML.ProtectedIDs{}
+	protect := func(href string) {
+		hrefPath, fragment := book.SplitHref(href)
+		if fragment == "" {
+			return
+		}
+		if protected[hrefPath] == nil {
+			protected[hrefPath] = cleanHTML.ProtectedIDs{}
+		}
+		protected[hrefPath][fragment] = true
+	}
+
+	var walk func(tocItems []book.TOCItem)
+	walk = func(tocItems []book.TOCItem) {
+		for _, item := range tocItems {
+			protect(item.Href)
+			walk(item.Children)
+		}
+	}
+	walk(b.TOCItems)
+	for _, pt := range b.PageTargets {
+		protect(pt.Href)
+	}
+	for _, navList := range b.NavLists {
+		for _, target := range navList.Targets {
+			protect(target.Href)
+		}
+	}
+	for _, guideItem := range b.GuideItems {
+		protect(guideItem.Href)
+	}
+
+	for _, r := range b.Resources {
+		if r.MediaType == "application/smil+xml" {
+			// Narration is synchronised with the elements it points to
+			for _, href := range graph.SMILHrefs(r.Contents) {
+				protect(book.ResolveHref(r.Path, href))
+			}
+			continue
+		}
+		if r.MediaType != "application/xhtml+xml" {
+			continue
+		}
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Contents))
+		if err != nil {
+			return nil, err
+		}
+		doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+			href := s.AttrOr("href", "")
+			if u, err := url.Parse(href); err == nil && u.Scheme != "" {
+				return
+			}
+			protect(book.ResolveHref(r.Path, href))
+		})
+	}
+	return protected, nil
+}
@@ -0,0 +1,280 @@
+package links
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/book/graph"
+)
+
+// Kinds of broken links
+const (
+	MissingFile     = "missing file"
+	MissingFragment = "missing fragment"
+	CaseMismatch    = "case mismatch"
+	BadEncoding     = "bad encoding"
+	WrongDepth      = "wrong relative path"
+)
+
+// A link that doesn't lead where it should
+type Problem struct {
+	Path string // The resource the link is in
+	Link string // The link as written
+	Kind string // One of the kinds of broken links
+	Fix  string // The link that works, or empty if it can't be fixed
+}
+
+func (p Problem) String() string {
+	if p.Fix == "" {
+		return fmt.Sprintf("%s: %s: %s", p.Path, p.Link, p.Kind)
+	}
+	return fmt.Sprintf("%s: %s: %s, fixed as %s", p.Path, p.Link, p.Kind, p.Fix)
+}
+
+// Elements and attributes that link to other resources
+var linkAttrs = []struct {
+	selector string
+	attr     string
+}{
+	{"a[href]", "href"},
+	{"img[src]", "src"},
+	{"link[href]", "href"},
+}
+
+// Find the links in pages and stylesheets that don't resolve, and how they
+// could be fixed
+func Check(b book.Book) []Problem {
+	problems, _ := check(b)
+	return problems
+}
+
+// Find the broken links among hrefs in a file that isn't a resource of the
+// book, such as the OPF guide or the NCX
+func CheckHrefs(b book.Book, from string, hrefs []string) []Problem {
+	c := newChecker(b)
+	var problems []Problem
+	checked := map[string]bool{}
+	for _, href := range hrefs {
+		if checked[href] {
+			continue
+		}
+		checked[href] = true
+		if problem, ok := c.checkLink(from, href); ok {
+			problems = append(problems, problem)
+		}
+	}
+	return problems
+}
+
+// Rewrite the links that can be fixed, and return every problem found
+func Fix(b *book.Book) []Problem {
+	problems, fixed := check(*b)
+	for i, r := range b.Resources {
+		if contents, ok := fixed[r.Path]; ok {
+			b.Resources[i].Contents = contents
+		}
+	}
+	return problems
+}
+
+type checker struct {
+	b             book.Book
+	resourcePaths map[string]bool
+	// Resource paths by their lower case, to find case-only mismatches
+	pathsByLower map[string][]string
+	// IDs of each page, parsed when first needed
+	ids map[string]map[string]bool
+}
+
+func newChecker(b book.Book) *checker {
+	c := &checker{
+		b:             b,
+		resourcePaths: map[string]bool{},
+		pathsByLower:  map[string][]string{},
+		ids:           map[string]map[string]bool{},
+	}
+	for _, r := range b.Resources {
+		c.resourcePaths[r.Path] = true
+		lower := strings.ToLower(r.Path)
+		c.pathsByLower[lower] = append(c.pathsByLower[lower], r.Path)
+	}
+	return c
+}
+
+// Problems, and the contents of resources with fixed links by path
+func check(b book.Book) ([]Problem, map[string][]byte) {
+	c := newChecker(b)
+
+	var problems []Problem
+	fixed := map[string][]byte{}
+	for _, r := range b.Resources {
+		var hrefs []string
+		switch r.MediaType {
+		case "application/xhtml+xml":
+			doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Contents))
+			if err != nil {
+				continue
+			}
+			for _, la := range linkAttrs {
+				doc.Find(la.selector).Each(func(_ int, s *goquery.Selection) {
+					hrefs = append(hrefs, s.AttrOr(la.attr, ""))
+				})
+			}
+		case "text/css":
+			graph.ReplaceURLs(string(r.Contents), func(u string) string {
+				hrefs = append(hrefs, u)
+				return u
+			})
+		case "application/smil+xml":
+			hrefs = graph.SMILHrefs(r.Contents)
+		default:
+			continue
+		}
+
+		contents := r.Contents
+		changed := false
+		checked := map[string]bool{}
+		for _, href := range hrefs {
+			if checked[href] {
+				continue
+			}
+			checked[href] = true
+			problem, ok := c.checkLink(r.Path, href)
+			if !ok {
+				continue
+			}
+			problems = append(problems, problem)
+			if problem.Fix == "" {
+				continue
+			}
+			contents = graph.ReplaceHref(contents, href, problem.Fix)
+			changed = true
+		}
+		if changed {
+			fixed[r.Path] = contents
+		}
+	}
+	return problems, fixed
+}
+
+// Check a link from a resource, returning a problem if it's broken
+func (c *checker) checkLink(docPath string, href string) (Problem, bool) {
+	if u, err := url.Parse(href); err == nil && (u.Scheme != "" || u.Host != "") {
+		return Problem{}, false
+	}
+	// Unparseable links may still be raw paths, like "100%.xhtml"
+	hrefPath, fragment := book.DecodeHref(href)
+	problem := Problem{Path: docPath, Link: href}
+
+	target := docPath
+	if hrefPath != "" {
+		target = book.AbsPath(docPath, hrefPath)
+	}
+	if !c.resourcePaths[target] {
+		fixedTarget, kind := c.findTarget(docPath, href, hrefPath, target)
+		problem.Kind = kind
+		if fixedTarget == "" {
+			return problem, true
+		}
+		problem.Fix = book.EncodeHref(book.RelPath(docPath, fixedTarget), c.fixFragment(fixedTarget, fragment))
+		return problem, true
+	}
+
+	if fragment == "" {
+		return problem, false
+	}
+	ids := c.pageIDs(target)
+	if ids == nil || ids[fragment] {
+		return problem, false
+	}
+	problem.Kind = MissingFragment
+	if fixed := c.fixFragment(target, fragment); fixed != fragment {
+		problem.Kind = CaseMismatch
+		problem.Fix = strings.SplitN(href, "#", 2)[0] + "#" + fixed
+	}
+	return problem, true
+}
+
+// Find the resource a broken link was meant for, and what was wrong with it
+func (c *checker) findTarget(docPath string, href string, hrefPath string, target string) (string, string) {
+	// A link that was encoded twice, or not decoded when it should have been
+	rawPath := strings.SplitN(href, "#", 2)[0]
+	for _, candidate := range []string{rawPath, unescape(hrefPath)} {
+		if candidate == "" {
+			continue
+		}
+		if candidateTarget := book.AbsPath(docPath, candidate); c.resourcePaths[candidateTarget] {
+			return candidateTarget, BadEncoding
+		}
+	}
+
+	if matches := c.pathsByLower[strings.ToLower(target)]; len(matches) == 1 {
+		return matches[0], CaseMismatch
+	}
+
+	// A link with too many or too few "../" still names the file
+	suffix := strings.TrimLeft(path.Clean("/"+hrefPath), "/")
+	var matches []string
+	for resourcePath := range c.resourcePaths {
+		if resourcePath == suffix || strings.HasSuffix(resourcePath, "/"+suffix) {
+			matches = append(matches, resourcePath)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], WrongDepth
+	}
+	return "", MissingFile
+}
+
+// The fragment as it appears in the target page, fixing its case if needed
+func (c *checker) fixFragment(target string, fragment string) string {
+	ids := c.pageIDs(target)
+	if fragment == "" || ids == nil || ids[fragment] {
+		return fragment
+	}
+	var matches []string
+	for id := range ids {
+		if strings.EqualFold(id, fragment) {
+			matches = append(matches, id)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0]
+	}
+	return fragment
+}
+
+// IDs in a page, or nil if the resource isn't a page
+func (c *checker) pageIDs(pagePath string) map[string]bool {
+	if ids, ok := c.ids[pagePath]; ok {
+		return ids
+	}
+	r, err := c.b.GetResource(pagePath)
+	if err != nil || r.MediaType != "application/xhtml+xml" {
+		c.ids[pagePath] = nil
+		return nil
+	}
+	ids := map[string]bool{}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Contents))
+	if err == nil {
+		doc.Find("[id]").Each(func(_ int, s *goquery.Selection) {
+			ids[s.AttrOr("id", "")] = true
+		})
+	}
+	c.ids[pagePath] = ids
+	return ids
+}
+
+func unescape(s string) string {
+	unescaped, err := url.PathUnescape(s)
+	if err != nil {
+		return s
+	}
+	return unescaped
+}
@@ -0,0 +1,70 @@
+package links
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+)
+
+func TestCheck(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{Path: "OEBPS/Text/Chapter 1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><head>
+<link href="../styles/main.css" rel="stylesheet" type="text/css"/>
+</head><body>
+<p id="Top"><a href="chapter%201.xhtml#top">Case</a>
+<a href="Chapter%25201.xhtml">Twice encoded</a>
+<a href="../../Images/a.png">Too deep</a>
+<a href="notes.xhtml#fn1">Note</a>
+<a href="#gone">Gone</a>
+<a href="http://example.com/missing.xhtml">Web</a>
+<img src="../Images/b.png"/></p>
+</body></html>`)},
+			{Path: "OEBPS/Text/notes.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><p id="fn1">Note</p></body></html>`)},
+			{Path: "OEBPS/Styles/main.css", MediaType: "text/css", Contents: []byte(`body { background: url(../images/a.png); }`)},
+			{Path: "OEBPS/Images/a.png", MediaType: "image/png"},
+		},
+	}
+	got := Check(b)
+	want := []Problem{
+		{Path: "OEBPS/Text/Chapter 1.xhtml", Link: "chapter%201.xhtml#top", Kind: CaseMismatch, Fix: "Chapter%201.xhtml#Top"},
+		{Path: "OEBPS/Text/Chapter 1.xhtml", Link: "Chapter%25201.xhtml", Kind: BadEncoding, Fix: "Chapter%201.xhtml"},
+		{Path: "OEBPS/Text/Chapter 1.xhtml", Link: "../../Images/a.png", Kind: WrongDepth, Fix: "../Images/a.png"},
+		{Path: "OEBPS/Text/Chapter 1.xhtml", Link: "#gone", Kind: MissingFragment},
+		{Path: "OEBPS/Text/Chapter 1.xhtml", Link: "../Images/b.png", Kind: MissingFile},
+		{Path: "OEBPS/Text/Chapter 1.xhtml", Link: "../styles/main.css", Kind: CaseMismatch, Fix: "../Styles/main.css"},
+		{Path: "OEBPS/Styles/main.css", Link: "../images/a.png", Kind: CaseMismatch, Fix: "../Images/a.png"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestFix(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{Path: "Text/1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<p id="Top"><a href="1.XHTML#top">Case</a> <a href="../../Images/a.png">Too deep</a> <a href="#gone">Gone</a></p>`)},
+			{Path: "Styles/main.css", MediaType: "text/css", Contents: []byte(`body { background: url(../images/a.png); }`)},
+			{Path: "Images/a.png", MediaType: "image/png"},
+		},
+	}
+	Fix(&b)
+	// Only the unfixable problem is left
+	got := Check(b)
+	want := []Problem{
+		{Path: "Text/1.xhtml", Link: "#gone", Kind: MissingFragment},
+	}
+	wantPage := `<p id="Top"><a href="1.xhtml#Top">Case</a> <a href="../Images/a.png">Too deep</a> <a href="#gone">Gone</a></p>`
+	wantCSS := `body { background: url(../Images/a.png); }`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("problems got != want:\n", diff)
+	}
+	if diff := cmp.Diff(wantPage, string(b.Resources[0].Contents)); diff != "" {
+		t.Error("page got != want:\n", diff)
+	}
+	if diff := cmp.Diff(wantCSS, string(b.Resources[1].Contents)); diff != "" {
+		t.Error("css got != want:\n", diff)
+	}
+}
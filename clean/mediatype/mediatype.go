@@ -0,0 +1,133 @@
+package mediatype
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/asavoy/reprint/book"
+)
+
+// Media types that mean the same as the sniffed media type, which are left
+// as they are
+var aliases = map[string][]string{
+	"font/ttf":  {"application/font-sfnt", "application/x-font-ttf", "application/x-font-truetype"},
+	"font/otf":  {"application/font-sfnt", "application/vnd.ms-opentype", "application/x-font-opentype"},
+	"font/woff": {"application/font-woff"},
+	"audio/mp4": {"video/mp4"},
+}
+
+// Binary formats by the bytes they start with
+var signatures = []struct {
+	offset    int
+	prefix    string
+	mediaType string
+}{
+	{0, "\xff\xd8\xff", "image/jpeg"},
+	{0, "\x89PNG\r\n\x1a\n", "image/png"},
+	{0, "GIF87a", "image/gif"},
+	{0, "GIF89a", "image/gif"},
+	{8, "WEBP", "image/webp"},
+	{0, "\x00\x01\x00\x00", "font/ttf"},
+	{0, "true", "font/ttf"},
+	{0, "OTTO", "font/otf"},
+	{0, "wOFF", "font/woff"},
+	{0, "wOF2", "font/woff2"},
+	{0, "ID3", "audio/mpeg"},
+	{0, "OggS", "audio/ogg"},
+	// MP4 files, and other ISO media files like AVIF and HEIC images, are
+	// only told apart by their major brand. Brands shared by audio and video,
+	// like isom and mp42, are left alone.
+	{4, "ftypM4A ", "audio/mp4"},
+	{4, "ftypM4B ", "audio/mp4"},
+}
+
+// XML formats by the name of their root element
+var xmlRoots = map[string]string{
+	"html": "application/xhtml+xml",
+	"svg":  "image/svg+xml",
+	"smil": "application/smil+xml",
+}
+
+// The media type of a resource going by its contents, or empty if it can't
+// be told. Stylesheets can only be told by their extension, as long as
+// they're text.
+func Sniff(contents []byte, resourcePath string) string {
+	for _, signature := range signatures {
+		end := signature.offset + len(signature.prefix)
+		if len(contents) >= end && string(contents[signature.offset:end]) == signature.prefix {
+			return signature.mediaType
+		}
+	}
+	// MPEG audio frames without an ID3 tag start with 11 set sync bits
+	if len(contents) >= 2 && contents[0] == 0xff && contents[1]&0xe0 == 0xe0 {
+		return "audio/mpeg"
+	}
+	if bytes.IndexByte(contents, 0) >= 0 {
+		return ""
+	}
+	if mediaType := sniffMarkup(contents); mediaType != "" {
+		return mediaType
+	}
+	if strings.ToLower(path.Ext(resourcePath)) == ".css" {
+		return "text/css"
+	}
+	return ""
+}
+
+func sniffMarkup(contents []byte) string {
+	contents = bytes.TrimPrefix(contents, []byte("\xef\xbb\xbf"))
+	decoder := xml.NewDecoder(bytes.NewReader(contents))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			return xmlRoots[strings.ToLower(token.Name.Local)]
+		case xml.CharData:
+			if len(bytes.TrimSpace(token)) > 0 {
+				return ""
+			}
+		}
+	}
+	// Pages that aren't well-formed enough to reach the root element
+	start := contents
+	if len(start) > 1024 {
+		start = start[:1024]
+	}
+	if bytes.Contains(bytes.ToLower(start), []byte("<html")) {
+		return "application/xhtml+xml"
+	}
+	return ""
+}
+
+// Correct the media types of resources whose contents say otherwise,
+// returning descriptions of the corrections
+func Correct(b *book.Book) []string {
+	var corrections []string
+	for i, r := range b.Resources {
+		sniffed := Sniff(r.Contents, r.Path)
+		if sniffed == "" || sniffed == r.MediaType || isAlias(sniffed, r.MediaType) {
+			continue
+		}
+		corrections = append(corrections, fmt.Sprintf("corrected media type of %s from %s to %s", r.Path, r.MediaType, sniffed))
+		b.Resources[i].MediaType = sniffed
+	}
+	return corrections
+}
+
+func isAlias(mediaType string, declared string) bool {
+	for _, alias := range aliases[mediaType] {
+		if alias == declared {
+			return true
+		}
+	}
+	return false
+}
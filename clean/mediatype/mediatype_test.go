@@ -0,0 +1,78 @@
+package mediatype
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+)
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		path     string
+		contents string
+		want     string
+	}{
+		{"a.jpg", "\xff\xd8\xff\xe0\x00\x10JFIF", "image/jpeg"},
+		{"a.png", "\x89PNG\r\n\x1a\n\x00\x00", "image/png"},
+		{"a.gif", "GIF89a\x01\x00", "image/gif"},
+		{"a.webp", "RIFF\x00\x00\x00\x00WEBPVP8 ", "image/webp"},
+		{"a.svg", `<?xml version="1.0"?><!-- drawn --><svg xmlns="http://www.w3.org/2000/svg"/>`, "image/svg+xml"},
+		{"a.xhtml", "\ufeff<?xml version='1.0' encoding='utf-8'?>\n<!DOCTYPE html>\n<html xmlns=\"http://www.w3.org/1999/xhtml\"><body></body></html>", "application/xhtml+xml"},
+		{"a.html", "<!DOCTYPE html><HTML><body><p>Caf&eacute;<br></body></HTML>", "application/xhtml+xml"},
+		{"a.css", "/* <html> */ p { margin: 0; }", "text/css"},
+		{"a.ttf", "\x00\x01\x00\x00\x00\x0c", "font/ttf"},
+		{"a.otf", "OTTO\x00\x0c", "font/otf"},
+		{"a.woff", "wOFF\x00\x01", "font/woff"},
+		{"a.woff2", "wOF2\x00\x01", "font/woff2"},
+		{"a.mp3", "ID3\x03\x00", "audio/mpeg"},
+		{"b.mp3", "\xff\xfb\x90\x64", "audio/mpeg"},
+		{"a.m4a", "\x00\x00\x00\x20ftypM4A ", "audio/mp4"},
+		{"a.m4b", "\x00\x00\x00\x20ftypM4B ", "audio/mp4"},
+		{"a.mp4", "\x00\x00\x00\x20ftypisom", ""},
+		{"a.avif", "\x00\x00\x00\x1cftypavif", ""},
+		{"a.heic", "\x00\x00\x00\x18ftypheic", ""},
+		{"a.smil", `<smil xmlns="http://www.w3.org/ns/SMIL" version="3.0"/>`, "application/smil+xml"},
+		{"a.js", "var x = '<html>';", ""},
+		// Obfuscated fonts can't be told
+		{"a.otf", "\x9a\x3b\x01\x7f", ""},
+	}
+	for _, test := range tests {
+		if got := Sniff([]byte(test.contents), test.path); got != test.want {
+			t.Errorf("Sniff(%q, %q) = %q, want %q", test.contents, test.path, got, test.want)
+		}
+	}
+}
+
+func TestCorrect(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{Path: "ch1.xhtml", MediaType: "text/html", Contents: []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><body/></html>`)},
+			{Path: "a.jpg", MediaType: "image/jpg", Contents: []byte("\xff\xd8\xff\xe0")},
+			{Path: "b.png", MediaType: "image/jpeg", Contents: []byte("\x89PNG\r\n\x1a\n")},
+			{Path: "font.otf", MediaType: "application/vnd.ms-opentype", Contents: []byte("OTTO")},
+			{Path: "secret.otf", MediaType: "font/otf", Contents: []byte("\x9a\x3b\x01\x7f")},
+			{Path: "style.css", MediaType: "text/plain", Contents: []byte("p { margin: 0; }")},
+			{Path: "a.avif", MediaType: "image/avif", Contents: []byte("\x00\x00\x00\x1cftypavif")},
+		},
+	}
+	got := Correct(&b)
+	want := []string{
+		"corrected media type of ch1.xhtml from text/html to application/xhtml+xml",
+		"corrected media type of a.jpg from image/jpg to image/jpeg",
+		"corrected media type of b.png from image/jpeg to image/png",
+		"corrected media type of style.css from text/plain to text/css",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	var gotTypes []string
+	for _, r := range b.Resources {
+		gotTypes = append(gotTypes, r.MediaType)
+	}
+	wantTypes := []string{"application/xhtml+xml", "image/jpeg", "image/png", "application/vnd.ms-opentype", "font/otf", "text/css", "image/avif"}
+	if diff := cmp.Diff(wantTypes, gotTypes); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
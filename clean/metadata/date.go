@@ -0,0 +1,101 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asavoy/reprint/book"
+)
+
+// Date events, named as in EPUB 2's opf:event
+const (
+	EventPublication  = "publication"
+	EventModification = "modification"
+	EventCreation     = "creation"
+)
+
+var eventAliases = map[string]string{
+	"":                     EventPublication,
+	"issued":               EventPublication,
+	"pub":                  EventPublication,
+	"published":            EventPublication,
+	"publication":          EventPublication,
+	"original-publication": "original-publication",
+	"dcterms:modified":     EventModification,
+	"mod":                  EventModification,
+	"modified":             EventModification,
+	"modification":         EventModification,
+	"created":              EventCreation,
+	"creation":             EventCreation,
+}
+
+// Layouts of dates seen in the wild, with the W3CDTF precision they map to
+var dateLayouts = []struct {
+	layout string
+	format string
+}{
+	{time.RFC3339Nano, "2006-01-02T15:04:05Z07:00"},
+	{"2006-01-02T15:04:05", "2006-01-02T15:04:05Z07:00"},
+	{"2006-01-02 15:04:05Z07:00", "2006-01-02T15:04:05Z07:00"},
+	{"2006-01-02 15:04:05", "2006-01-02T15:04:05Z07:00"},
+	{"2006-01-02", "2006-01-02"},
+	{"2006-1-2", "2006-01-02"},
+	{"2006/01/02", "2006-01-02"},
+	{"2006-01", "2006-01"},
+	{"2006", "2006"},
+	{"January 2, 2006", "2006-01-02"},
+	{"Jan 2, 2006", "2006-01-02"},
+	{"2 January 2006", "2006-01-02"},
+	{"2 Jan 2006", "2006-01-02"},
+	{"January 2006", "2006-01"},
+	{"Jan 2006", "2006-01"},
+	{"Mon, 02 Jan 2006 15:04:05 -0700", "2006-01-02T15:04:05Z07:00"},
+}
+
+// Normalise a date to W3CDTF, keeping the precision of the original
+func NormaliseDate(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	for _, l := range dateLayouts {
+		t, err := time.Parse(l.layout, value)
+		if err != nil {
+			continue
+		}
+		return t.Format(l.format), nil
+	}
+	return "", errors.New("unrecognised date: " + value)
+}
+
+// Normalise dates to W3CDTF and their events to the names used for EPUB 2.
+// Modification dates are in UTC, as required for EPUB 3's dcterms:modified.
+// Dates that can't be parsed are kept as they are, with a warning.
+func NormaliseDates(b *book.Book) []string {
+	var warnings []string
+	var dates []book.Date
+	for _, date := range b.Dates {
+		event := strings.ToLower(strings.TrimSpace(date.Event))
+		if alias, ok := eventAliases[event]; ok {
+			event = alias
+		}
+		value, err := NormaliseDate(date.Value)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("kept %s date as it is: %s", event, err))
+			value = date.Value
+		} else if event == EventModification {
+			value = parseW3CDTF(value).UTC().Format("2006-01-02T15:04:05Z")
+		}
+		dates = append(dates, book.Date{Event: event, Value: value})
+	}
+	b.Dates = dates
+	return warnings
+}
+
+func parseW3CDTF(value string) time.Time {
+	for _, layout := range []string{"2006-01-02T15:04:05Z07:00", "2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,63 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+)
+
+func TestNormaliseDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"1998-01-01", "1998-01-01"},
+		{"2019-05-31T14:42:00.544171+00:00", "2019-05-31T14:42:00Z"},
+		{"2019-05-31T14:42:00", "2019-05-31T14:42:00Z"},
+		{"2016/07/01", "2016-07-01"},
+		{"July 2016", "2016-07"},
+		{"July 4, 1998", "1998-07-04"},
+		{" 1850 ", "1850"},
+	}
+	for _, test := range tests {
+		got, err := NormaliseDate(test.value)
+		if err != nil {
+			t.Error(err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %s != want %s", test.value, got, test.want)
+		}
+	}
+	if _, err := NormaliseDate("sometime last year"); err == nil {
+		t.Error("expected error for unrecognised date")
+	}
+}
+
+func TestNormaliseDates(t *testing.T) {
+	b := book.Book{
+		Metadata: book.Metadata{
+			Dates: []book.Date{
+				{Value: "July 2016"},
+				{Event: "Published", Value: "2016-08-01"},
+				{Event: "modification", Value: "2019-05-31T14:42:00+10:00"},
+				{Event: "conversion", Value: "unknown"},
+			},
+		},
+	}
+	gotWarnings := NormaliseDates(&b)
+	wantWarnings := []string{"kept conversion date as it is: unrecognised date: unknown"}
+	wantDates := []book.Date{
+		{Event: "publication", Value: "2016-07"},
+		{Event: "publication", Value: "2016-08-01"},
+		{Event: "modification", Value: "2019-05-31T04:42:00Z"},
+		{Event: "conversion", Value: "unknown"},
+	}
+	if diff := cmp.Diff(wantWarnings, gotWarnings); diff != "" {
+		t.Error("warnings got != want:\n", diff)
+	}
+	if diff := cmp.Diff(wantDates, b.Dates); diff != "" {
+		t.Error("dates got != want:\n", diff)
+	}
+}
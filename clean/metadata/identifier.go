@@ -0,0 +1,51 @@
+package metadata
+
+import (
+	"strings"
+
+	"github.com/asavoy/reprint/book"
+)
+
+// Normalise ISBNs to ISBN-13 and generate a unique identifier when the book
+// has none. Returns a warning for each identifier that couldn't be normalised.
+func NormaliseIdentifiers(b *book.Book) []string {
+	var warnings []string
+	hasISBN := false
+	for i, identifier := range b.Identifiers {
+		if !isISBN(identifier) {
+			continue
+		}
+		isbn, err := NormaliseISBN(identifier.Value)
+		if err != nil {
+			warnings = append(warnings, err.Error())
+			continue
+		}
+		b.Identifiers[i] = book.Identifier{Scheme: "ISBN", Value: isbn}
+		hasISBN = true
+	}
+
+	if b.Identifier == "" {
+		b.Identifier = book.GenerateUUID(*b)
+	} else if !hasISBN {
+		// The unique identifier is kept as is because reading systems use it
+		// to track the book, but it's often the only record of the ISBN
+		if isbn, err := NormaliseISBN(b.Identifier); err == nil && looksLikeISBN(b.Identifier) {
+			b.Identifiers = append(b.Identifiers, book.Identifier{Scheme: "ISBN", Value: isbn})
+		}
+	}
+	return warnings
+}
+
+func isISBN(identifier book.Identifier) bool {
+	return strings.EqualFold(identifier.Scheme, "ISBN") ||
+		strings.HasPrefix(strings.ToLower(identifier.Value), "urn:isbn:")
+}
+
+// Avoid mistaking arbitrary numbers that happen to pass the ISBN-10 checksum
+func looksLikeISBN(value string) bool {
+	lower := strings.ToLower(value)
+	return strings.HasPrefix(lower, "urn:isbn:") ||
+		strings.HasPrefix(lower, "isbn") ||
+		strings.Count(value, "-") == 3 || strings.Count(value, "-") == 4 ||
+		strings.HasPrefix(value, "978") || strings.HasPrefix(value, "979")
+}
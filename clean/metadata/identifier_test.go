@@ -0,0 +1,47 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+)
+
+func TestNormaliseIdentifiers(t *testing.T) {
+	b := book.Book{
+		Metadata: book.Metadata{
+			Identifier: "0-8044-2957-X",
+			Identifiers: []book.Identifier{
+				{Scheme: "isbn", Value: "1-61729-178-8"},
+				{Scheme: "AMAZON", Value: "B01234"},
+			},
+		},
+	}
+	gotWarnings := NormaliseIdentifiers(&b)
+	wantWarnings := []string{"invalid ISBN-10 checksum: 1-61729-178-8"}
+	wantIdentifiers := []book.Identifier{
+		{Scheme: "isbn", Value: "1-61729-178-8"},
+		{Scheme: "AMAZON", Value: "B01234"},
+		{Scheme: "ISBN", Value: "9780804429573"},
+	}
+	if diff := cmp.Diff(wantWarnings, gotWarnings); diff != "" {
+		t.Error("warnings got != want:\n", diff)
+	}
+	if diff := cmp.Diff(wantIdentifiers, b.Identifiers); diff != "" {
+		t.Error("identifiers got != want:\n", diff)
+	}
+	if b.Identifier != "0-8044-2957-X" {
+		t.Error("unique identifier changed:", b.Identifier)
+	}
+}
+
+func TestNormaliseIdentifiersGenerates(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{{Path: "1.xhtml", Contents: []byte("<p>1</p>")}},
+	}
+	NormaliseIdentifiers(&b)
+	if want := book.GenerateUUID(b); b.Identifier != want {
+		t.Errorf("got %q, want %q", b.Identifier, want)
+	}
+}
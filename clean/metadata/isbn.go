@@ -0,0 +1,79 @@
+package metadata
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var isbnPrefixRegexp = regexp.MustCompile(`(?i)^(urn:isbn:|isbn(-1[03])?:?)\s*`)
+
+// Normalise an ISBN-10 or ISBN-13 to an unhyphenated ISBN-13, checking its
+// checksum
+func NormaliseISBN(value string) (string, error) {
+	isbn := isbnPrefixRegexp.ReplaceAllString(strings.TrimSpace(value), "")
+	isbn = strings.NewReplacer("-", "", " ", "").Replace(isbn)
+	isbn = strings.ToUpper(isbn)
+	switch len(isbn) {
+	case 10:
+		if !ValidISBN10(isbn) {
+			return "", errors.New("invalid ISBN-10 checksum: " + value)
+		}
+		return ISBN10To13(isbn), nil
+	case 13:
+		if !ValidISBN13(isbn) {
+			return "", errors.New("invalid ISBN-13 checksum: " + value)
+		}
+		return isbn, nil
+	}
+	return "", errors.New("not an ISBN: " + value)
+}
+
+func ValidISBN10(isbn string) bool {
+	if len(isbn) != 10 {
+		return false
+	}
+	sum := 0
+	for i, c := range isbn {
+		var digit int
+		if c >= '0' && c <= '9' {
+			digit = int(c - '0')
+		} else if c == 'X' && i == 9 {
+			digit = 10
+		} else {
+			return false
+		}
+		sum += (10 - i) * digit
+	}
+	return sum%11 == 0
+}
+
+func ValidISBN13(isbn string) bool {
+	if len(isbn) != 13 {
+		return false
+	}
+	for _, c := range isbn {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return isbn13CheckDigit(isbn[:12]) == isbn[12]
+}
+
+// Convert a valid ISBN-10 to ISBN-13, which uses the 978 prefix
+func ISBN10To13(isbn string) string {
+	prefix := "978" + isbn[:9]
+	return prefix + string(isbn13CheckDigit(prefix))
+}
+
+func isbn13CheckDigit(first12 string) byte {
+	sum := 0
+	for i, c := range first12 {
+		digit := int(c - '0')
+		if i%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return byte('0' + (10-sum%10)%10)
+}
@@ -0,0 +1,30 @@
+package metadata
+
+import (
+	"testing"
+)
+
+func TestNormaliseISBN(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"1-61729-178-1", "9781617291784", false},
+		{"urn:isbn:978-1-61729-178-4", "9781617291784", false},
+		{"ISBN 0-8044-2957-X", "9780804429573", false},
+		{"isbn-13: 9780804429573", "9780804429573", false},
+		{"1-61729-178-8", "", true},
+		{"9781617291785", "", true},
+		{"urn:uuid:1234", "", true},
+	}
+	for _, test := range tests {
+		got, err := NormaliseISBN(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error %v", test.value, err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %s != want %s", test.value, got, test.want)
+		}
+	}
+}
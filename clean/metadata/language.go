@@ -0,0 +1,115 @@
+package metadata
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/asavoy/reprint/book"
+)
+
+// Common languages by English name, ISO 639-2 code or deprecated tag
+var languageAliases = map[string]string{
+	"arabic": "ar", "ara": "ar",
+	"chinese": "zh", "chi": "zh", "zho": "zh",
+	"czech": "cs", "cze": "cs", "ces": "cs",
+	"danish": "da", "dan": "da",
+	"dutch": "nl", "dut": "nl", "nld": "nl",
+	"english": "en", "eng": "en",
+	"finnish": "fi", "fin": "fi",
+	"french": "fr", "fre": "fr", "fra": "fr",
+	"german": "de", "ger": "de", "deu": "de",
+	"greek": "el", "gre": "el", "ell": "el",
+	"hebrew": "he", "heb": "he", "iw": "he",
+	"hindi": "hi", "hin": "hi",
+	"hungarian": "hu", "hun": "hu",
+	"indonesian": "id", "ind": "id", "in": "id",
+	"italian": "it", "ita": "it",
+	"japanese": "ja", "jpn": "ja",
+	"korean": "ko", "kor": "ko",
+	"latin": "la", "lat": "la",
+	"norwegian": "no", "nor": "no",
+	"persian": "fa", "per": "fa", "fas": "fa",
+	"polish": "pl", "pol": "pl",
+	"portuguese": "pt", "por": "pt",
+	"romanian": "ro", "rum": "ro", "ron": "ro",
+	"russian": "ru", "rus": "ru",
+	"spanish": "es", "spa": "es",
+	"swedish": "sv", "swe": "sv",
+	"thai": "th", "tha": "th",
+	"turkish": "tr", "tur": "tr",
+	"ukrainian": "uk", "ukr": "uk",
+	"vietnamese": "vi", "vie": "vi",
+	"yiddish": "yi", "yid": "yi", "ji": "yi",
+}
+
+var (
+	languageTagRegexp = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{1,8})*$`)
+	pageLangRegexp    = regexp.MustCompile(`<html\b[^>]*?\b(?:xml:)?lang=["']([^"']*)["']`)
+)
+
+// Normalise a language name or malformed tag to a BCP 47 tag, e.g. "English"
+// to "en", and "en_us" to "en-US"
+func NormaliseLanguage(value string) (string, error) {
+	tag := strings.ToLower(strings.TrimSpace(value))
+	tag = strings.Replace(tag, "_", "-", -1)
+	if alias, ok := languageAliases[tag]; ok {
+		return alias, nil
+	}
+	// Names with a region, e.g. "English (United States)", keep the language
+	if i := strings.IndexAny(tag, " (,"); i > 0 {
+		if alias, ok := languageAliases[tag[:i]]; ok {
+			return alias, nil
+		}
+	}
+	if !languageTagRegexp.MatchString(tag) {
+		return "", errors.New("unrecognised language: " + value)
+	}
+
+	subtags := strings.Split(tag, "-")
+	if alias, ok := languageAliases[subtags[0]]; ok {
+		subtags[0] = alias
+	}
+	for i := 1; i < len(subtags); i++ {
+		switch {
+		case len(subtags[i]) == 1:
+			// Extensions and private use keep their case
+			return strings.Join(subtags, "-"), nil
+		case len(subtags[i]) == 2:
+			subtags[i] = strings.ToUpper(subtags[i])
+		case len(subtags[i]) == 4:
+			subtags[i] = strings.ToUpper(subtags[i][:1]) + subtags[i][1:]
+		}
+	}
+	return strings.Join(subtags, "-"), nil
+}
+
+// Normalise the book's language, falling back to the language declared by
+// its pages when it's missing or unrecognised
+func NormaliseBookLanguage(b *book.Book) []string {
+	var warnings []string
+	if b.Language != "" {
+		lang, err := NormaliseLanguage(b.Language)
+		if err == nil {
+			b.Language = lang
+			return warnings
+		}
+		warnings = append(warnings, err.Error())
+	}
+	for _, spineItem := range b.SpineItems {
+		for _, r := range b.Resources {
+			if r.ID != spineItem.ID {
+				continue
+			}
+			matches := pageLangRegexp.FindSubmatch(r.Contents)
+			if matches == nil {
+				continue
+			}
+			if lang, err := NormaliseLanguage(string(matches[1])); err == nil {
+				b.Language = lang
+				return warnings
+			}
+		}
+	}
+	return append(warnings, "can't determine language")
+}
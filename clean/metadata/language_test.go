@@ -0,0 +1,51 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/asavoy/reprint/book"
+)
+
+func TestNormaliseLanguage(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"en", "en"},
+		{"English", "en"},
+		{"en_US", "en-US"},
+		{"EN-gb", "en-GB"},
+		{"zh-hant-tw", "zh-Hant-TW"},
+		{"fre", "fr"},
+		{"iw", "he"},
+		{"Japanese (Japan)", "ja"},
+	}
+	for _, test := range tests {
+		got, err := NormaliseLanguage(test.value)
+		if err != nil {
+			t.Error(err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %s != want %s", test.value, got, test.want)
+		}
+	}
+	if _, err := NormaliseLanguage("Klingon!"); err == nil {
+		t.Error("expected error for unrecognised language")
+	}
+}
+
+func TestNormaliseBookLanguage(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{ID: "p1", Contents: []byte(`<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="fr_CA">`)},
+		},
+		SpineItems: []book.SpineItem{{ID: "p1", Linear: true}},
+	}
+	warnings := NormaliseBookLanguage(&b)
+	if len(warnings) != 0 {
+		t.Error("unexpected warnings:", warnings)
+	}
+	if b.Language != "fr-CA" {
+		t.Errorf("got %s != want fr-CA", b.Language)
+	}
+}
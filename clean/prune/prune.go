@@ -0,0 +1,66 @@
+package prune
+
+import (
+	"path"
+	"sort"
+
+	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/book/graph"
+)
+
+type Options struct {
+	// path.Match patterns of resources to keep even when nothing refers to
+	// them, like "OEBPS/Fonts/*"
+	Keep []string
+}
+
+// Paths of resources that can't be reached from the spine, TOC, guide or
+// cover, through links, images, stylesheets and CSS urls, in path order
+func Unreferenced(b book.Book, options Options) []string {
+	g := graph.New(&b)
+	reachable := map[string]bool{}
+	queue := []string{""}
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		for _, ref := range g.References(from) {
+			if reachable[ref.To] {
+				continue
+			}
+			if _, ok := g.ByPath(ref.To); !ok {
+				continue
+			}
+			reachable[ref.To] = true
+			queue = append(queue, ref.To)
+		}
+	}
+
+	var unreferenced []string
+	for _, r := range b.Resources {
+		if !reachable[r.Path] && !keep(r.Path, options.Keep) {
+			unreferenced = append(unreferenced, r.Path)
+		}
+	}
+	sort.Strings(unreferenced)
+	return unreferenced
+}
+
+// Remove the resources that nothing refers to, returning their paths
+func Prune(b *book.Book, options Options) []string {
+	unreferenced := Unreferenced(*b, options)
+	g := graph.New(b)
+	for _, resourcePath := range unreferenced {
+		// Can't fail, as the paths were just found in the book
+		_ = g.Remove(resourcePath)
+	}
+	return unreferenced
+}
+
+func keep(resourcePath string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, resourcePath); matched {
+			return true
+		}
+	}
+	return false
+}
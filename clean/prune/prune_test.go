@@ -0,0 +1,66 @@
+package prune
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+)
+
+func TestUnreferenced(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{ID: "ch1", Path: "Text/ch1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><head>
+<link href="../Styles/main.css" rel="stylesheet" type="text/css"/>
+</head><body><p><a href="notes.xhtml#fn1">Note</a> <img src="../Images/a.png"/></p></body></html>`)},
+			{ID: "notes", Path: "Text/notes.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><p id="fn1">Note</p></body></html>`)},
+			{ID: "orphan", Path: "Text/orphan.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><img src="../Images/b.png"/></body></html>`)},
+			{ID: "css", Path: "Styles/main.css", MediaType: "text/css", Contents: []byte(`body { background: url(../Images/bg.png); }`)},
+			{ID: "unused-css", Path: "Styles/unused.css", MediaType: "text/css", Contents: []byte(`@font-face { src: url(../Fonts/serif.otf); }`)},
+			{ID: "a", Path: "Images/a.png", MediaType: "image/png"},
+			{ID: "b", Path: "Images/b.png", MediaType: "image/png"},
+			{ID: "bg", Path: "Images/bg.png", MediaType: "image/png"},
+			{ID: "cover", Path: "Images/cover.jpg", MediaType: "image/jpeg"},
+			{ID: "font", Path: "Fonts/serif.otf", MediaType: "font/otf"},
+			{ID: "sans", Path: "Fonts/sans.otf", MediaType: "font/otf"},
+		},
+		SpineItems:   []book.SpineItem{{ID: "ch1", Linear: true}},
+		CoverImageID: "cover",
+	}
+	tests := []struct {
+		keep []string
+		want []string
+	}{
+		{nil, []string{"Fonts/sans.otf", "Fonts/serif.otf", "Images/b.png", "Styles/unused.css", "Text/orphan.xhtml"}},
+		{[]string{"Fonts/*", "Text/orphan.xhtml"}, []string{"Images/b.png", "Styles/unused.css"}},
+	}
+	for _, test := range tests {
+		got := Unreferenced(b, Options{Keep: test.keep})
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("keep %v: got != want:\n%s", test.keep, diff)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{ID: "ch1", Path: "Text/ch1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><img src="../Images/a.png"/></body></html>`)},
+			{ID: "orphan", Path: "Text/orphan.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><img src="../Images/b.png"/></body></html>`)},
+			{ID: "a", Path: "Images/a.png", MediaType: "image/png"},
+			{ID: "b", Path: "Images/b.png", MediaType: "image/png"},
+			{ID: "sans", Path: "Fonts/sans.otf", MediaType: "font/otf"},
+		},
+		SpineItems: []book.SpineItem{{ID: "ch1", Linear: true}},
+	}
+	Prune(&b, Options{Keep: []string{"Fonts/sans.otf"}})
+	var got []string
+	for _, r := range b.Resources {
+		got = append(got, r.Path)
+	}
+	want := []string{"Text/ch1.xhtml", "Images/a.png", "Fonts/sans.otf"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
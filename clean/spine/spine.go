@@ -0,0 +1,181 @@
+package spine
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/asavoy/reprint/book"
+)
+
+// Guide and epub:type types of pages that are read out of order
+var auxiliaryTypes = map[string]bool{
+	"notes":     true,
+	"footnotes": true,
+	"endnotes":  true,
+	"rearnotes": true,
+}
+
+// Filename words of pages that are read out of order, or not at all
+var auxiliaryNames = map[string]bool{
+	"footnotes":      true,
+	"endnotes":       true,
+	"ads":            true,
+	"advert":         true,
+	"adverts":        true,
+	"advertisement":  true,
+	"advertisements": true,
+}
+
+// Find where the spine and TOC disagree
+func Check(b book.Book) []string {
+	var warnings []string
+	resourcesByID := map[string]book.Resource{}
+	for _, r := range b.Resources {
+		resourcesByID[r.ID] = r
+	}
+	inSpine := map[string]bool{}
+	for _, spineItem := range b.SpineItems {
+		r, ok := resourcesByID[spineItem.ID]
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("spine refers to missing resource %s", spineItem.ID))
+			continue
+		}
+		if inSpine[r.Path] {
+			warnings = append(warnings, fmt.Sprintf("spine refers to %s more than once", r.Path))
+		}
+		inSpine[r.Path] = true
+	}
+	for _, item := range flattenTOC(b.TOCItems) {
+		itemPath := hrefPath(item.Href)
+		if inSpine[itemPath] {
+			continue
+		}
+		if _, err := b.GetResource(itemPath); err != nil {
+			warnings = append(warnings, fmt.Sprintf("TOC entry %q links to missing resource %s", item.Label, itemPath))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("TOC entry %q links to %s, which isn't in the spine", item.Label, itemPath))
+		}
+	}
+	return warnings
+}
+
+// Drop spine items for missing resources, add content documents that the TOC
+// links to in TOC order, and mark notes and ads as non-linear. Returns
+// descriptions of the repairs.
+func Repair(b *book.Book) []string {
+	var repairs []string
+	resourcesByID := map[string]book.Resource{}
+	for _, r := range b.Resources {
+		resourcesByID[r.ID] = r
+	}
+
+	var spineItems []book.SpineItem
+	seen := map[string]bool{}
+	for _, spineItem := range b.SpineItems {
+		if _, ok := resourcesByID[spineItem.ID]; !ok {
+			repairs = append(repairs, fmt.Sprintf("removed missing resource %s from the spine", spineItem.ID))
+			continue
+		}
+		if seen[spineItem.ID] {
+			repairs = append(repairs, fmt.Sprintf("removed repeated resource %s from the spine", spineItem.ID))
+			continue
+		}
+		seen[spineItem.ID] = true
+		spineItems = append(spineItems, spineItem)
+	}
+
+	// Missing pages go after the page of the TOC entry before them
+	insertAt := 0
+	for _, item := range flattenTOC(b.TOCItems) {
+		r, err := b.GetResource(hrefPath(item.Href))
+		if err != nil || r.MediaType != "application/xhtml+xml" {
+			continue
+		}
+		if i := spineIndex(spineItems, r.ID); i >= 0 {
+			insertAt = i + 1
+			continue
+		}
+		spineItems = append(spineItems[:insertAt], append([]book.SpineItem{{ID: r.ID, Linear: true}}, spineItems[insertAt:]...)...)
+		insertAt++
+		repairs = append(repairs, fmt.Sprintf("added %s to the spine", r.Path))
+	}
+
+	guideTypes := map[string]string{}
+	for _, guideItem := range b.GuideItems {
+		guideTypes[hrefPath(guideItem.Href)] = guideItem.Type
+	}
+	for i, spineItem := range spineItems {
+		r := resourcesByID[spineItem.ID]
+		if spineItem.Linear && isAuxiliary(r, guideTypes[r.Path]) {
+			spineItems[i].Linear = false
+			repairs = append(repairs, fmt.Sprintf("marked %s as non-linear", r.Path))
+		}
+	}
+
+	b.SpineItems = spineItems
+	return repairs
+}
+
+func isAuxiliary(r book.Resource, guideType string) bool {
+	if auxiliaryTypes[guideType] {
+		return true
+	}
+	name := strings.TrimSuffix(path.Base(r.Path), path.Ext(r.Path))
+	for _, word := range strings.FieldsFunc(strings.ToLower(name), isSeparator) {
+		if auxiliaryNames[word] {
+			return true
+		}
+	}
+	if r.MediaType != "application/xhtml+xml" {
+		return false
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(r.Contents)))
+	if err != nil {
+		return false
+	}
+	// The page is auxiliary when its body, or its only section, is typed so
+	body := doc.Find("body")
+	sections := body.ChildrenFiltered("section, aside")
+	for _, s := range []*goquery.Selection{body, sections} {
+		if s.Length() != 1 {
+			continue
+		}
+		for _, epubType := range strings.Fields(s.AttrOr("epub:type", "")) {
+			if auxiliaryTypes[epubType] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isSeparator(r rune) bool {
+	return r == '-' || r == '_' || r == '.' || r == ' '
+}
+
+func spineIndex(spineItems []book.SpineItem, ID string) int {
+	for i, spineItem := range spineItems {
+		if spineItem.ID == ID {
+			return i
+		}
+	}
+	return -1
+}
+
+// TOC entries in reading order
+func flattenTOC(tocItems []book.TOCItem) []book.TOCItem {
+	var flattened []book.TOCItem
+	for _, item := range tocItems {
+		flattened = append(flattened, item)
+		flattened = append(flattened, flattenTOC(item.Children)...)
+	}
+	return flattened
+}
+
+func hrefPath(href string) string {
+	hrefPath, _ := book.SplitHref(href)
+	return hrefPath
+}
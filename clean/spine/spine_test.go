@@ -0,0 +1,78 @@
+package spine
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+)
+
+func TestCheck(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{ID: "c1", Path: "text/1.xhtml", MediaType: "application/xhtml+xml"},
+			{ID: "c2", Path: "text/2.xhtml", MediaType: "application/xhtml+xml"},
+			{ID: "img", Path: "images/map.png", MediaType: "image/png"},
+		},
+		SpineItems: []book.SpineItem{{ID: "c1", Linear: true}, {ID: "gone", Linear: true}},
+		TOCItems: []book.TOCItem{
+			{Label: "One", Href: "text/1.xhtml", Children: []book.TOCItem{
+				{Label: "Two", Href: "text/2.xhtml#start"},
+			}},
+			{Label: "Map", Href: "images/map.png"},
+		},
+	}
+	got := Check(b)
+	want := []string{
+		"spine refers to missing resource gone",
+		`TOC entry "Two" links to text/2.xhtml, which isn't in the spine`,
+		`TOC entry "Map" links to images/map.png, which isn't in the spine`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{ID: "c1", Path: "text/1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><p>One</p></body></html>`)},
+			{ID: "c2", Path: "text/2.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><p>Two</p></body></html>`)},
+			{ID: "c3", Path: "text/3.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><section epub:type="endnotes"><p>Notes</p></section></body></html>`)},
+			{ID: "ads", Path: "text/other-ads.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><p>Buy</p></body></html>`)},
+		},
+		SpineItems: []book.SpineItem{
+			{ID: "c1", Linear: true},
+			{ID: "gone", Linear: true},
+			{ID: "c3", Linear: true},
+			{ID: "ads", Linear: true},
+		},
+		TOCItems: []book.TOCItem{
+			{Label: "One", Href: "text/1.xhtml"},
+			{Label: "Two", Href: "text/2.xhtml#start"},
+		},
+	}
+	gotRepairs := Repair(&b)
+	wantSpine := []book.SpineItem{
+		{ID: "c1", Linear: true},
+		{ID: "c2", Linear: true},
+		{ID: "c3", Linear: false},
+		{ID: "ads", Linear: false},
+	}
+	wantRepairs := []string{
+		"removed missing resource gone from the spine",
+		"added text/2.xhtml to the spine",
+		"marked text/3.xhtml as non-linear",
+		"marked text/other-ads.xhtml as non-linear",
+	}
+	if diff := cmp.Diff(wantSpine, b.SpineItems); diff != "" {
+		t.Error("spine got != want:\n", diff)
+	}
+	if diff := cmp.Diff(wantRepairs, gotRepairs); diff != "" {
+		t.Error("repairs got != want:\n", diff)
+	}
+	if got := Check(b); len(got) > 0 {
+		t.Error("repaired book should pass, got:\n", got)
+	}
+}
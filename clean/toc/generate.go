@@ -0,0 +1,191 @@
+package toc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/asavoy/reprint/book"
+	cleanHTML "github.com/asavoy/reprint/clean/html"
+)
+
+const DefaultDepth = 3
+
+type Options struct {
+	// How many levels of nested headings to include
+	Depth int
+	// Keep the existing entries for pages they link to, and only use headings
+	// for the other pages. Entries for pages outside the spine are kept too.
+	Merge bool
+}
+
+// A TOC is useless when it can't take the reader anywhere but the start
+func Useless(b book.Book) bool {
+	return countItems(b.TOCItems) <= 1
+}
+
+// Build the TOC from the h1-h6 headings of the spine pages, adding IDs to
+// headings so they can be linked to. The TOC is left alone if the pages have
+// no headings.
+func Generate(b *book.Book, options Options) error {
+	if options.Depth <= 0 {
+		options.Depth = DefaultDepth
+	}
+	pages := spinePages(*b)
+	// Existing entries for pages outside the spine, like notes left out of
+	// the reading order, are kept after the entry that preceded them
+	existingByPath := map[string][]int{}
+	following := map[int][]book.TOCItem{}
+	var tocItems []book.TOCItem
+	if options.Merge {
+		pagePaths := map[string]bool{}
+		for _, page := range pages {
+			pagePaths[page.Path] = true
+		}
+		last := -1
+		for i, item := range b.TOCItems {
+			itemPath, _ := book.SplitHref(item.Href)
+			switch {
+			case pagePaths[itemPath]:
+				existingByPath[itemPath] = append(existingByPath[itemPath], i)
+				last = i
+			case last < 0:
+				tocItems = append(tocItems, item)
+			default:
+				following[last] = append(following[last], item)
+			}
+		}
+	}
+
+	generated := 0
+	for _, page := range pages {
+		if existing, ok := existingByPath[page.Path]; ok {
+			for _, i := range existing {
+				tocItems = append(tocItems, b.TOCItems[i])
+				tocItems = append(tocItems, following[i]...)
+			}
+			continue
+		}
+		headingItems, contents, err := pageHeadings(page, options.Depth)
+		if err != nil {
+			return err
+		}
+		if len(headingItems) == 0 {
+			continue
+		}
+		tocItems = append(tocItems, headingItems...)
+		generated += len(headingItems)
+		for i := range b.Resources {
+			if b.Resources[i].Path == page.Path {
+				b.Resources[i].Contents = contents
+			}
+		}
+	}
+	if generated > 0 {
+		b.TOCItems = tocItems
+	}
+	return nil
+}
+
+// XHTML pages in reading order
+func spinePages(b book.Book) []book.Resource {
+	var pages []book.Resource
+	for _, spineItem := range b.SpineItems {
+		for _, r := range b.Resources {
+			if r.ID == spineItem.ID && r.MediaType == "application/xhtml+xml" {
+				pages = append(pages, r)
+			}
+		}
+	}
+	return pages
+}
+
+// TOC entries for the headings of a page, nested by heading level, and the
+// page's contents with IDs added to the headings that lacked them
+func pageHeadings(page book.Resource, depth int) ([]book.TOCItem, []byte, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(cleanHTML.ConvertXHTMLToHTML(string(page.Contents))))
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := map[string]bool{}
+	doc.Find("[id]").Each(func(_ int, s *goquery.Selection) {
+		ids[s.AttrOr("id", "")] = true
+	})
+
+	var headings []heading
+	doc.Find("body").Find("h1, h2, h3, h4, h5, h6").Each(func(_ int, s *goquery.Selection) {
+		label := strings.Join(strings.Fields(s.Text()), " ")
+		if label == "" {
+			return
+		}
+		headingLevel, _ := strconv.Atoi(strings.TrimPrefix(goquery.NodeName(s), "h"))
+		headings = append(headings, heading{level: headingLevel, label: label, selection: s})
+	})
+	addedIDs := false
+	linkTo := func(s *goquery.Selection) string {
+		id := s.AttrOr("id", "")
+		if id == "" {
+			id = uniqueID(ids)
+			s.SetAttr("id", id)
+			addedIDs = true
+		}
+		return page.Path + "#" + id
+	}
+	tocItems := nestHeadings(headings, depth, linkTo)
+
+	if !addedIDs {
+		return tocItems, page.Contents, nil
+	}
+	docHTML, err := doc.Html()
+	if err != nil {
+		return nil, nil, err
+	}
+	return tocItems, []byte(docHTML), nil
+}
+
+type heading struct {
+	level     int
+	label     string
+	selection *goquery.Selection
+}
+
+// Nest each heading under the closest preceding heading of a higher level
+func nestHeadings(headings []heading, depth int, linkTo func(*goquery.Selection) string) []book.TOCItem {
+	var tocItems []book.TOCItem
+	for i := 0; i < len(headings); {
+		end := i + 1
+		for end < len(headings) && headings[end].level > headings[i].level {
+			end++
+		}
+		item := book.TOCItem{
+			Label: headings[i].label,
+			Href:  linkTo(headings[i].selection),
+		}
+		if depth > 1 {
+			item.Children = nestHeadings(headings[i+1:end], depth-1, linkTo)
+		}
+		tocItems = append(tocItems, item)
+		i = end
+	}
+	return tocItems
+}
+
+func uniqueID(ids map[string]bool) string {
+	for i := 1; ; i++ {
+		id := fmt.Sprintf("reprint-toc-%d", i)
+		if !ids[id] {
+			ids[id] = true
+			return id
+		}
+	}
+}
+
+func countItems(tocItems []book.TOCItem) int {
+	count := len(tocItems)
+	for _, item := range tocItems {
+		count += countItems(item.Children)
+	}
+	return count
+}
@@ -0,0 +1,84 @@
+package toc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+)
+
+func TestGenerate(t *testing.T) {
+	page2 := `<html><body>
+<h2 id="c3">Chapter 3</h2>
+</body></html>`
+	b := book.Book{
+		Resources: []book.Resource{
+			{ID: "c1", Path: "text/1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body>
+<h1 id="part">Part  One</h1>
+<h2>Chapter 1</h2>
+<h3>Section</h3>
+<h2>Chapter 2</h2>
+<h2> </h2>
+</body></html>`)},
+			{ID: "c2", Path: "text/2.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(page2)},
+			{ID: "img", Path: "images/1.png", MediaType: "image/png"},
+		},
+		SpineItems: []book.SpineItem{{ID: "c1", Linear: true}, {ID: "c2", Linear: true}},
+		TOCItems:   []book.TOCItem{{Label: "Start", Href: "text/1.xhtml"}},
+	}
+	if !Useless(b) {
+		t.Error("one entry TOC should be useless")
+	}
+	err := Generate(&b, Options{Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []book.TOCItem{
+		{Label: "Part One", Href: "text/1.xhtml#part", Children: []book.TOCItem{
+			{Label: "Chapter 1", Href: "text/1.xhtml#reprint-toc-1"},
+			{Label: "Chapter 2", Href: "text/1.xhtml#reprint-toc-2"},
+		}},
+		{Label: "Chapter 3", Href: "text/2.xhtml#c3"},
+	}
+	if diff := cmp.Diff(want, b.TOCItems); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	page := string(b.Resources[0].Contents)
+	if !strings.Contains(page, `<h2 id="reprint-toc-1">Chapter 1</h2>`) || strings.Contains(page, `<h3 id=`) {
+		t.Error("IDs should only be added to included headings:\n", page)
+	}
+	if string(b.Resources[1].Contents) != page2 {
+		t.Error("pages that already have heading IDs shouldn't be rewritten")
+	}
+}
+
+func TestGenerateMerge(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{ID: "c1", Path: "text/1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><h1 id="part">Part One</h1><h2>Chapter 1</h2></body></html>`)},
+			{ID: "c2", Path: "text/2.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html><body><h2 id="c3">Chapter 3</h2></body></html>`)},
+		},
+		SpineItems: []book.SpineItem{{ID: "c1", Linear: true}, {ID: "c2", Linear: true}},
+		// Notes are often left out of the spine
+		TOCItems: []book.TOCItem{
+			{Label: "Cover", Href: "text/cover.xhtml"},
+			{Label: "Contents", Href: "text/2.xhtml#c3"},
+			{Label: "Notes", Href: "text/notes.xhtml"},
+		},
+	}
+	err := Generate(&b, Options{Depth: 1, Merge: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []book.TOCItem{
+		{Label: "Cover", Href: "text/cover.xhtml"},
+		{Label: "Part One", Href: "text/1.xhtml#part"},
+		{Label: "Contents", Href: "text/2.xhtml#c3"},
+		{Label: "Notes", Href: "text/notes.xhtml"},
+	}
+	if diff := cmp.Diff(want, b.TOCItems); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
@@ -0,0 +1,203 @@
+package toc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/asavoy/reprint/book"
+)
+
+var romanNumeralRegex = regexp.MustCompile(`^M{0,4}(CM|CD|D?C{0,3})(XC|XL|L?X{0,3})(IX|IV|V?I{0,3})$`)
+
+type TidyOptions struct {
+	// Entries nested deeper than this are lifted up to this level, or 0 to
+	// keep any depth
+	MaxDepth int
+	// Number entries by their position, like "2.1"
+	Number bool
+	// Convert labels in all caps to title case, keeping known acronyms
+	TitleCase bool
+}
+
+// Normalise labels, flatten deep nesting and remove redundant entries, for
+// both the NCX and the navigation document
+func Tidy(b *book.Book, options TidyOptions) {
+	tocItems := normaliseLabels(b.TOCItems, options.TitleCase)
+	tocItems = removeRedundantChildren(tocItems)
+	if options.MaxDepth > 0 {
+		tocItems = flatten(tocItems, options.MaxDepth)
+	}
+	if options.Number {
+		tocItems = number(tocItems, "")
+	}
+	b.TOCItems = tocItems
+}
+
+func normaliseLabels(tocItems []book.TOCItem, titleCase bool) []book.TOCItem {
+	var normalised []book.TOCItem
+	for _, item := range tocItems {
+		item.Label = NormaliseLabel(item.Label)
+		if titleCase {
+			item.Label = TitleCaseLabel(item.Label)
+		}
+		item.Children = normaliseLabels(item.Children, titleCase)
+		normalised = append(normalised, item)
+	}
+	return normalised
+}
+
+// Collapse whitespace and drop duplicated numbering like "1. 1. Introduction"
+func NormaliseLabel(label string) string {
+	words := strings.Fields(label)
+	if len(words) >= 2 && words[0] == words[1] && isNumbering(words[0]) {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// Convert a label in all caps to title case, leaving acronyms and roman
+// numerals alone. Labels with any lower case letters are left as they are.
+func TitleCaseLabel(label string) string {
+	words := strings.Fields(label)
+	if !isAllCaps(words) {
+		return label
+	}
+	for i, word := range words {
+		words[i] = titleCase(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// Acronyms that are as likely to be in a heading in all caps as in any other,
+// so are kept as they are
+var acronyms = map[string]bool{
+	"AI": true, "API": true, "BBC": true, "CEO": true, "CIA": true,
+	"CPU": true, "CSS": true, "DIY": true, "DNA": true, "EU": true,
+	"FAQ": true, "FBI": true, "GPS": true, "HTML": true, "HTTP": true,
+	"ID": true, "IT": true, "JSON": true, "NASA": true, "NATO": true,
+	"PC": true, "PDF": true, "SQL": true, "TV": true, "UK": true,
+	"UN": true, "URL": true, "US": true, "USA": true, "UX": true,
+	"WWII": true, "XML": true,
+}
+
+// Whether the words have several letters, none of them lower case
+func isAllCaps(words []string) bool {
+	letters := 0
+	for _, word := range words {
+		for _, r := range word {
+			if unicode.IsLower(r) {
+				return false
+			}
+			if unicode.IsLetter(r) {
+				letters++
+			}
+		}
+	}
+	return letters > 1
+}
+
+// Capitalise the first letter of a word, leaving acronyms and roman
+// numerals alone
+func titleCase(word string) string {
+	if isRomanNumeral(word) || acronyms[strings.TrimFunc(word, isNotLetter)] {
+		return word
+	}
+	runes := []rune(strings.ToLower(word))
+	for i, r := range runes {
+		if unicode.IsLetter(r) {
+			runes[i] = unicode.ToUpper(r)
+			break
+		}
+	}
+	return string(runes)
+}
+
+func isNotLetter(r rune) bool {
+	return !unicode.IsLetter(r)
+}
+
+func isRomanNumeral(word string) bool {
+	word = strings.TrimRight(word, ".:)")
+	if len(word) < 2 {
+		// Single letters like "I" or "A" are as likely to be words
+		return false
+	}
+	return romanNumeralRegex.MatchString(word)
+}
+
+// Whether a word numbers an entry, like "2", "2.1", "2." or "2)"
+func isNumbering(word string) bool {
+	word = strings.TrimRight(word, ".:)")
+	if word == "" {
+		return false
+	}
+	for _, part := range strings.Split(word, ".") {
+		if part == "" {
+			return false
+		}
+		for _, r := range part {
+			if !unicode.IsDigit(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Children that link to the same place as their parent add nothing, but
+// their own children are kept
+func removeRedundantChildren(tocItems []book.TOCItem) []book.TOCItem {
+	var kept []book.TOCItem
+	for _, item := range tocItems {
+		item.Children = liftSameTarget(removeRedundantChildren(item.Children), item.Href)
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+func liftSameTarget(children []book.TOCItem, href string) []book.TOCItem {
+	var kept []book.TOCItem
+	for _, child := range children {
+		if child.Href == href {
+			kept = append(kept, liftSameTarget(child.Children, href)...)
+		} else {
+			kept = append(kept, child)
+		}
+	}
+	return kept
+}
+
+// Entries below maxDepth become siblings at maxDepth, in reading order
+func flatten(tocItems []book.TOCItem, maxDepth int) []book.TOCItem {
+	var flattened []book.TOCItem
+	for _, item := range tocItems {
+		if maxDepth <= 1 {
+			children := item.Children
+			item.Children = nil
+			flattened = append(flattened, item)
+			flattened = append(flattened, flatten(children, 1)...)
+		} else {
+			item.Children = flatten(item.Children, maxDepth-1)
+			flattened = append(flattened, item)
+		}
+	}
+	return flattened
+}
+
+// Prefix labels with their position, replacing any numbering they had
+func number(tocItems []book.TOCItem, prefix string) []book.TOCItem {
+	var numbered []book.TOCItem
+	for i, item := range tocItems {
+		position := fmt.Sprintf("%s%d", prefix, i+1)
+		words := strings.Fields(item.Label)
+		if len(words) > 1 && isNumbering(words[0]) {
+			words = words[1:]
+		}
+		item.Label = strings.TrimSpace(position + " " + strings.Join(words, " "))
+		item.Children = number(item.Children, position+".")
+		numbered = append(numbered, item)
+	}
+	return numbered
+}
@@ -0,0 +1,67 @@
+package toc
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+)
+
+func TestNormaliseLabel(t *testing.T) {
+	tests := map[string]string{
+		"  Chapter\n  One ":  "Chapter One",
+		"CHAPTER ONE":        "CHAPTER ONE",
+		"1. 1. Introduction": "1. Introduction",
+		"1. 2. Introduction": "1. 2. Introduction",
+		"Chapter 1":          "Chapter 1",
+	}
+	for label, want := range tests {
+		if got := NormaliseLabel(label); got != want {
+			t.Errorf("NormaliseLabel(%q) = %q, want %q", label, got, want)
+		}
+	}
+}
+
+func TestTitleCaseLabel(t *testing.T) {
+	tests := map[string]string{
+		"CHAPTER ONE":             "Chapter One",
+		"PART II: THE \"RETURN\"": "Part II: The \"Return\"",
+		"SQL BASICS":              "SQL Basics",
+		"WHY (HTML) MATTERS":      "Why (HTML) Matters",
+		"NASA and me":             "NASA and me",
+		"I":                       "I",
+	}
+	for label, want := range tests {
+		if got := TitleCaseLabel(label); got != want {
+			t.Errorf("TitleCaseLabel(%q) = %q, want %q", label, got, want)
+		}
+	}
+}
+
+func TestTidy(t *testing.T) {
+	b := book.Book{TOCItems: []book.TOCItem{
+		{Label: "PART ONE", Href: "1.xhtml", Children: []book.TOCItem{
+			{Label: "Part One", Href: "1.xhtml", Children: []book.TOCItem{
+				{Label: "3. Chapter", Href: "1.xhtml#c1", Children: []book.TOCItem{
+					{Label: "Section", Href: "1.xhtml#s1", Children: []book.TOCItem{
+						{Label: "Subsection", Href: "1.xhtml#s1.1"},
+					}},
+				}},
+			}},
+		}},
+		{Label: "Appendix", Href: "2.xhtml"},
+	}}
+	Tidy(&b, TidyOptions{MaxDepth: 2, Number: true, TitleCase: true})
+	want := []book.TOCItem{
+		{Label: "1 Part One", Href: "1.xhtml", Children: []book.TOCItem{
+			{Label: "1.1 Chapter", Href: "1.xhtml#c1"},
+			{Label: "1.2 Section", Href: "1.xhtml#s1"},
+			{Label: "1.3 Subsection", Href: "1.xhtml#s1.1"},
+		}},
+		{Label: "2 Appendix", Href: "2.xhtml"},
+	}
+	if diff := cmp.Diff(want, b.TOCItems); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/asavoy/reprint/epub/check"
+)
+
+// Validate books against the core EPUB rules
+func Check(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "show findings as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: reprint check [flags] book.epub...\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("expected at least one book")
+	}
+
+	errorCount := 0
+	results := map[string][]check.Finding{}
+	for _, bookPath := range flags.Args() {
+		findings, err := check.File(bookPath)
+		if err != nil {
+			return err
+		}
+		results[bookPath] = findings
+		errorCount += countErrors(findings)
+		if *asJSON {
+			continue
+		}
+		for _, finding := range findings {
+			fmt.Printf("%s: %s\n", bookPath, finding)
+		}
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return err
+		}
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("found %d errors", errorCount)
+	}
+	return nil
+}
+
+func countErrors(findings []check.Finding) int {
+	count := 0
+	for _, finding := range findings {
+		if finding.Severity == check.SeverityError {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	bookJSON "github.com/asavoy/reprint/book/json"
+	"github.com/asavoy/reprint/epub"
+)
+
+// Dump a book as JSON, to stdout or a file
+func Export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	withContents := flags.Bool("contents", false, "include resource contents, base64 encoded")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: reprint export [flags] book.epub [book.json]\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return errors.New("expected a book and optional output path")
+	}
+
+	b, err := epub.Read(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	jsonBytes, err := bookJSON.Marshal(b, *withContents)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() == 1 {
+		_, err = os.Stdout.Write(jsonBytes)
+		return err
+	}
+	return ioutil.WriteFile(flags.Arg(1), jsonBytes, 0644)
+}
+
+// Load metadata and TOC edits from JSON back into a book
+func Import(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	outPath := flags.String("o", "", "write the edited book to `path` instead of in place")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: reprint import [flags] book.epub book.json\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("expected a book and a JSON file")
+	}
+	inPath := flags.Arg(0)
+
+	b, err := epub.Read(inPath)
+	if err != nil {
+		return err
+	}
+	jsonBytes, err := ioutil.ReadFile(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	jb, err := bookJSON.Unmarshal(jsonBytes)
+	if err != nil {
+		return err
+	}
+	err = bookJSON.Apply(&b, jb)
+	if err != nil {
+		return err
+	}
+
+	if *outPath == "" {
+		*outPath = inPath
+	}
+	return writeReplacing(*outPath, b)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	cleanLayout "github.com/asavoy/reprint/clean/layout"
+	"github.com/asavoy/reprint/epub"
+)
+
+// Move the resources of a book into Text/, Images/, Styles/ and Fonts/
+// folders with safe file names
+func Layout(args []string) error {
+	flags := flag.NewFlagSet("layout", flag.ExitOnError)
+	dryRun := flags.Bool("n", false, "only list the moves that would be made")
+	outPath := flags.String("o", "", "write the reorganised book to `path` instead of in place")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: reprint layout [flags] book.epub\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one book")
+	}
+	inPath := flags.Arg(0)
+
+	b, err := epub.Read(inPath)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		for _, move := range cleanLayout.Plan(b) {
+			fmt.Println(move)
+		}
+		return nil
+	}
+	moves, err := cleanLayout.Normalise(&b)
+	if err != nil {
+		return err
+	}
+	for _, move := range moves {
+		fmt.Println(move)
+	}
+	if *outPath == "" {
+		*outPath = inPath
+	}
+	return writeReplacing(*outPath, b)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	cleanLinks "github.com/asavoy/reprint/clean/links"
+	"github.com/asavoy/reprint/epub"
+)
+
+// Report broken links in a book, optionally fixing those that can be
+func Links(args []string) error {
+	flags := flag.NewFlagSet("links", flag.ExitOnError)
+	fix := flags.Bool("fix", false, "rewrite the links that can be fixed")
+	outPath := flags.String("o", "", "with -fix, write the fixed book to `path` instead of in place")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: reprint links [flags] book.epub\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one book")
+	}
+	inPath := flags.Arg(0)
+
+	b, err := epub.Read(inPath)
+	if err != nil {
+		return err
+	}
+	if !*fix {
+		for _, problem := range cleanLinks.Check(b) {
+			fmt.Println(problem)
+		}
+		return nil
+	}
+	for _, problem := range cleanLinks.Fix(&b) {
+		fmt.Println(problem)
+	}
+	if *outPath == "" {
+		*outPath = inPath
+	}
+	return writeReplacing(*outPath, b)
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/epub"
+)
+
+// Flag that can be repeated to collect each of its values
+type repeatedFlag []string
+
+func (f *repeatedFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *repeatedFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// Show or edit the metadata of a book
+func Meta(args []string) error {
+	flags := flag.NewFlagSet("meta", flag.ExitOnError)
+	var sets, adds, removes repeatedFlag
+	flags.Var(&sets, "set", "set a field, as `field=value` (repeatable)")
+	flags.Var(&adds, "add", "add a value to a field, as `field=value` (repeatable)")
+	flags.Var(&removes, "remove", "remove a field, or one of its values as `field[=value]` (repeatable)")
+	from := flags.String("from", "", "merge metadata from a JSON or OPF sidecar `file`")
+	outPath := flags.String("o", "", "write the edited book to `path` instead of in place")
+	asJSON := flags.Bool("json", false, "show metadata as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: reprint meta [flags] book.epub\n\nfields: %s, identifier:<scheme>\n\n", strings.Join(book.FieldNames, ", "))
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one book")
+	}
+	inPath := flags.Arg(0)
+
+	b, err := epub.Read(inPath)
+	if err != nil {
+		return err
+	}
+
+	if *from == "" && len(sets) == 0 && len(adds) == 0 && len(removes) == 0 {
+		return showMeta(b, *asJSON)
+	}
+
+	if *from != "" {
+		sidecar, err := readSidecar(*from)
+		if err != nil {
+			return err
+		}
+		b.Merge(sidecar)
+	}
+	for _, set := range sets {
+		name, value := splitField(set)
+		if name == "cover" {
+			err = setCover(&b, value)
+		} else {
+			err = b.SetField(name, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, add := range adds {
+		name, value := splitField(add)
+		if err := b.AddField(name, value); err != nil {
+			return err
+		}
+	}
+	for _, remove := range removes {
+		name, value := splitField(remove)
+		if err := b.RemoveField(name, value); err != nil {
+			return err
+		}
+	}
+
+	if *outPath == "" {
+		*outPath = inPath
+	}
+	return writeReplacing(*outPath, b)
+}
+
+func showMeta(b book.Book, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(b.Metadata)
+	}
+	for _, field := range b.Fields() {
+		fmt.Printf("%s: %s\n", field.Name, field.Value)
+	}
+	return nil
+}
+
+func splitField(arg string) (string, string) {
+	parts := strings.SplitN(arg, "=", 2)
+	if len(parts) == 1 {
+		return strings.TrimSpace(parts[0]), ""
+	}
+	return strings.TrimSpace(parts[0]), parts[1]
+}
+
+// Sidecars are either JSON in the form shown by -json, or an OPF file
+func readSidecar(sidecarPath string) (book.Metadata, error) {
+	contents, err := ioutil.ReadFile(sidecarPath)
+	if err != nil {
+		return book.Metadata{}, err
+	}
+	if strings.ToLower(filepath.Ext(sidecarPath)) == ".opf" {
+		return epub.ReadMetadata(contents)
+	}
+	var metadata book.Metadata
+	err = json.Unmarshal(contents, &metadata)
+	if err != nil {
+		return book.Metadata{}, err
+	}
+	return metadata, nil
+}
+
+// The cover may be a resource already in the book, or an image file to add
+func setCover(b *book.Book, value string) error {
+	if err := b.SetField("cover", value); err == nil {
+		return nil
+	}
+	contents, err := ioutil.ReadFile(value)
+	if err != nil {
+		return fmt.Errorf("can't find cover resource or file %s", value)
+	}
+	mediaType := mime.TypeByExtension(strings.ToLower(filepath.Ext(value)))
+	if !strings.HasPrefix(mediaType, "image/") {
+		return fmt.Errorf("cover %s isn't an image", value)
+	}
+	ID := book.UniqueResourceID(b.Resources, "reprint-cover")
+	b.Resources = append(b.Resources, book.Resource{
+		ID:        ID,
+		Path:      book.UniqueResourcePath(b.Resources, path.Join("images", "reprint-cover"+filepath.Ext(value))),
+		MediaType: mediaType,
+		Contents:  contents,
+	})
+	b.CoverImageID = ID
+	return nil
+}
+
+// Write via a temporary file, so that a failed write can't destroy the
+// original when editing in place
+func writeReplacing(outPath string, b book.Book) error {
+	tmpPath := outPath + ".reprint-tmp"
+	warnings, err := epub.WriteWithWarnings(tmpPath, b)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	printWarnings(warnings)
+	return os.Rename(tmpPath, outPath)
+}
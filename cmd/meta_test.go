@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/asavoy/reprint/epub"
+	"github.com/asavoy/reprint/epub/check"
+	"github.com/asavoy/reprint/epub/epubtest"
+)
+
+// Books without an identifier get one when they're written, so editing
+// them gives a valid book
+func TestMetaSetWithoutIdentifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reprint-meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bookPath := epubtest.WriteZip(t, dir, []epubtest.File{
+		{Name: "mimetype", Contents: "application/epub+zip"},
+		{Name: "META-INF/container.xml", Contents: `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+	<rootfiles><rootfile full-path="content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`},
+		{Name: "content.opf", Contents: `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="uid">
+	<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+		<dc:title>A Sample Book</dc:title>
+		<dc:language>en</dc:language>
+	</metadata>
+	<manifest><item id="c1" href="1.xhtml" media-type="application/xhtml+xml"/></manifest>
+	<spine><itemref idref="c1"/></spine>
+</package>`},
+		{Name: "1.xhtml", Contents: `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>One</title></head><body><p>One</p></body></html>`},
+	})
+
+	if err := Meta([]string{"-set", "language=fr", bookPath}); err != nil {
+		t.Fatal(err)
+	}
+	findings, err := check.File(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, finding := range findings {
+		if finding.Severity == check.SeverityError {
+			t.Error(finding)
+		}
+	}
+	b, err := epub.Read(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Identifier == "" || b.Language != "fr" {
+		t.Errorf("got identifier %q and language %q", b.Identifier, b.Language)
+	}
+}
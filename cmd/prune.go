@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	cleanPrune "github.com/asavoy/reprint/clean/prune"
+	"github.com/asavoy/reprint/epub"
+)
+
+// Remove the resources of a book that nothing refers to
+func Prune(args []string) error {
+	flags := flag.NewFlagSet("prune", flag.ExitOnError)
+	var keep repeatedFlag
+	flags.Var(&keep, "keep", "keep resources matching the path `pattern`, like OEBPS/Fonts/* (repeatable)")
+	dryRun := flags.Bool("n", false, "only list the resources that would be removed")
+	outPath := flags.String("o", "", "write the pruned book to `path` instead of in place")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: reprint prune [flags] book.epub\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one book")
+	}
+	inPath := flags.Arg(0)
+
+	b, err := epub.Read(inPath)
+	if err != nil {
+		return err
+	}
+	options := cleanPrune.Options{Keep: keep}
+	if *dryRun {
+		for _, resourcePath := range cleanPrune.Unreferenced(b, options) {
+			fmt.Println(resourcePath)
+		}
+		return nil
+	}
+	for _, resourcePath := range cleanPrune.Prune(&b, options) {
+		fmt.Println("removed", resourcePath)
+	}
+	if *outPath == "" {
+		*outPath = inPath
+	}
+	return writeReplacing(*outPath, b)
+}
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/asavoy/reprint/clean"
 	"github.com/asavoy/reprint/epub"
+	"github.com/asavoy/reprint/epub/check"
 )
 
 func Run(inPath, outPath string) error {
@@ -10,13 +13,30 @@ func Run(inPath, outPath string) error {
 	if err != nil {
 		return err
 	}
-	err = clean.Clean(&book)
+	warnings, err := clean.Clean(&book)
 	if err != nil {
 		return err
 	}
-	err = epub.Write(outPath, book)
+	printWarnings(warnings)
+	warnings, err = epub.WriteWithWarnings(outPath, book)
+	if err != nil {
+		return err
+	}
+	printWarnings(warnings)
+
+	// Problems in our own output are bugs, so report them loudly
+	findings, err := check.File(outPath)
 	if err != nil {
 		return err
 	}
+	for _, finding := range findings {
+		fmt.Println("check:", finding)
+	}
 	return nil
 }
+
+func printWarnings(warnings []string) {
+	for _, warning := range warnings {
+		fmt.Println("warning:", warning)
+	}
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	cleanSpine "github.com/asavoy/reprint/clean/spine"
+	"github.com/asavoy/reprint/epub"
+)
+
+// Report where the spine and TOC of a book disagree, optionally repairing
+// the spine
+func Spine(args []string) error {
+	flags := flag.NewFlagSet("spine", flag.ExitOnError)
+	fix := flags.Bool("fix", false, "drop missing pages from the spine, add pages the TOC links to, and mark notes and ads as non-linear")
+	outPath := flags.String("o", "", "with -fix, write the repaired book to `path` instead of in place")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: reprint spine [flags] book.epub\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one book")
+	}
+	inPath := flags.Arg(0)
+
+	b, err := epub.Read(inPath)
+	if err != nil {
+		return err
+	}
+	if !*fix {
+		for _, warning := range cleanSpine.Check(b) {
+			fmt.Println(warning)
+		}
+		return nil
+	}
+	for _, repair := range cleanSpine.Repair(&b) {
+		fmt.Println(repair)
+	}
+	if *outPath == "" {
+		*outPath = inPath
+	}
+	return writeReplacing(*outPath, b)
+}
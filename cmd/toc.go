@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	cleanTOC "github.com/asavoy/reprint/clean/toc"
+	"github.com/asavoy/reprint/epub"
+)
+
+// Tidy the TOC of a book, optionally rebuilding it from its headings
+func TOC(args []string) error {
+	flags := flag.NewFlagSet("toc", flag.ExitOnError)
+	headings := flags.Bool("headings", false, "rebuild the TOC from the headings of pages")
+	merge := flags.Bool("merge", false, "with -headings, keep existing entries and only add headings for pages without any")
+	depth := flags.Int("depth", 0, "nest entries up to `n` levels deep (default any depth, or 3 levels of headings with -headings)")
+	number := flags.Bool("number", false, "number entries by position, like 2.1")
+	titleCase := flags.Bool("titlecase", false, "convert labels in all caps to title case, keeping known acronyms")
+	outPath := flags.String("o", "", "write the edited book to `path` instead of in place")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: reprint toc [flags] book.epub\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one book")
+	}
+	inPath := flags.Arg(0)
+
+	b, err := epub.Read(inPath)
+	if err != nil {
+		return err
+	}
+	if *headings {
+		err = cleanTOC.Generate(&b, cleanTOC.Options{Depth: *depth, Merge: *merge})
+		if err != nil {
+			return err
+		}
+	}
+	cleanTOC.Tidy(&b, cleanTOC.TidyOptions{MaxDepth: *depth, Number: *number, TitleCase: *titleCase})
+
+	if *outPath == "" {
+		*outPath = inPath
+	}
+	return writeReplacing(*outPath, b)
+}
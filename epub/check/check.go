@@ -0,0 +1,395 @@
+package check
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/asavoy/reprint/book"
+	cleanLinks "github.com/asavoy/reprint/clean/links"
+	"github.com/asavoy/reprint/epub/container"
+	"github.com/asavoy/reprint/epub/ncx"
+	"github.com/asavoy/reprint/epub/opf"
+)
+
+const (
+	// The book won't load in some reading systems
+	SeverityError = "error"
+	// The book loads, but something is likely to be missing or wrong
+	SeverityWarning = "warning"
+)
+
+// A problem found in a book
+type Finding struct {
+	Severity string `json:"severity"`
+	Path     string `json:"path,omitempty"` // The file in the book, if the problem is in one
+	Message  string `json:"message"`
+}
+
+func (f Finding) String() string {
+	if f.Path == "" {
+		return fmt.Sprintf("%s: %s", f.Severity, f.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Path, f.Message)
+}
+
+// Media types that are plausible for each file extension
+var mediaTypesByExt = map[string][]string{
+	".xhtml": {"application/xhtml+xml"},
+	".html":  {"application/xhtml+xml"},
+	".htm":   {"application/xhtml+xml"},
+	".css":   {"text/css"},
+	".jpg":   {"image/jpeg"},
+	".jpeg":  {"image/jpeg"},
+	".png":   {"image/png"},
+	".gif":   {"image/gif"},
+	".webp":  {"image/webp"},
+	".svg":   {"image/svg+xml"},
+	".ncx":   {ncx.MediaType},
+	".ttf":   {"font/ttf", "application/font-sfnt", "application/x-font-ttf", "application/x-font-truetype"},
+	".otf":   {"font/otf", "application/font-sfnt", "application/vnd.ms-opentype", "application/x-font-opentype"},
+	".woff":  {"font/woff", "application/font-woff"},
+	".woff2": {"font/woff2"},
+	".mp3":   {"audio/mpeg"},
+	".m4a":   {"audio/mp4"},
+	".mp4":   {"audio/mp4", "video/mp4"},
+	".smil":  {"application/smil+xml"},
+	".js":    {"application/javascript", "text/javascript", "application/ecmascript"},
+}
+
+// Media types of documents that can be in the spine
+var contentMediaTypes = map[string]bool{
+	"application/xhtml+xml": true,
+	"image/svg+xml":         true,
+}
+
+// Media types of resources whose links are checked, as clean/links does
+var linkingMediaTypes = map[string]bool{
+	"application/xhtml+xml": true,
+	"text/css":              true,
+	"application/smil+xml":  true,
+}
+
+const xmlNS = "http://www.w3.org/XML/1998/namespace"
+
+type checker struct {
+	files     map[string]*zip.File
+	fileOrder []*zip.File
+	findings  []Finding
+
+	// The manifest items found in the file, for checking links
+	b book.Book
+}
+
+// Check an EPUB file against the core EPUB 2 and 3 rules. Only problems that
+// stop the file being checked at all are returned as errors.
+func File(filepath string) ([]Finding, error) {
+	r, err := zip.OpenReader(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	c := &checker{
+		files:     map[string]*zip.File{},
+		fileOrder: r.File,
+	}
+	for _, f := range r.File {
+		c.files[f.Name] = f
+	}
+	c.checkMimetype()
+	opfPath, ok := c.checkContainer()
+	if ok {
+		c.checkPackage(opfPath)
+	}
+	return c.findings, nil
+}
+
+func (c *checker) errorf(filePath string, format string, args ...interface{}) {
+	c.findings = append(c.findings, Finding{Severity: SeverityError, Path: filePath, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) warnf(filePath string, format string, args ...interface{}) {
+	c.findings = append(c.findings, Finding{Severity: SeverityWarning, Path: filePath, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) read(filePath string) ([]byte, bool) {
+	f, ok := c.files[filePath]
+	if !ok {
+		return nil, false
+	}
+	fc, err := f.Open()
+	if err != nil {
+		c.errorf(filePath, "can't be read: %s", err)
+		return nil, false
+	}
+	defer fc.Close()
+	contents, err := ioutil.ReadAll(fc)
+	if err != nil {
+		c.errorf(filePath, "can't be read: %s", err)
+		return nil, false
+	}
+	return contents, true
+}
+
+// The mimetype file must come first and be stored uncompressed, so that the
+// file can be identified by its first bytes
+func (c *checker) checkMimetype() {
+	if len(c.fileOrder) == 0 || c.fileOrder[0].Name != "mimetype" {
+		c.errorf("mimetype", "must be the first file")
+	}
+	f, ok := c.files["mimetype"]
+	if !ok {
+		c.errorf("mimetype", "is missing")
+		return
+	}
+	if f.Method != zip.Store {
+		c.errorf("mimetype", "must be stored without compression")
+	}
+	contents, _ := c.read("mimetype")
+	if string(contents) != "application/epub+zip" {
+		c.errorf("mimetype", "must contain application/epub+zip, not %q", contents)
+	}
+}
+
+func (c *checker) checkContainer() (string, bool) {
+	contents, ok := c.read(container.Path)
+	if !ok {
+		c.errorf(container.Path, "is missing")
+		return "", false
+	}
+	ctr, err := container.Read(contents)
+	if err != nil {
+		c.errorf(container.Path, "isn't valid: %s", err)
+		return "", false
+	}
+	for _, rootFile := range ctr.RootFiles {
+		if rootFile.MediaType != opf.MediaType {
+			continue
+		}
+		if _, ok := c.files[rootFile.FullPath]; !ok {
+			c.errorf(container.Path, "rootfile %s is missing", rootFile.FullPath)
+			return "", false
+		}
+		return rootFile.FullPath, true
+	}
+	c.errorf(container.Path, "has no rootfile of type %s", opf.MediaType)
+	return "", false
+}
+
+func (c *checker) checkPackage(opfPath string) {
+	contents, _ := c.read(opfPath)
+	pack, err := opf.Read(contents)
+	if err != nil {
+		c.errorf(opfPath, "isn't valid: %s", err)
+		return
+	}
+
+	foundUniqueID := false
+	for _, identifier := range pack.Metadata.Identifiers {
+		if identifier.ID != "" && identifier.ID == pack.UniqueIdentifier {
+			foundUniqueID = true
+			if strings.TrimSpace(identifier.Value) == "" {
+				c.errorf(opfPath, "unique identifier %q is empty", identifier.ID)
+			}
+		}
+	}
+	if !foundUniqueID {
+		c.errorf(opfPath, "unique-identifier %q doesn't match an identifier", pack.UniqueIdentifier)
+	}
+
+	itemsByID := map[string]opf.ManifestItem{}
+	itemPaths := map[string]string{}
+	inManifest := map[string]bool{opfPath: true}
+	var navItems []string
+	for _, item := range pack.Manifest.Items {
+		if _, ok := itemsByID[item.ID]; ok {
+			c.errorf(opfPath, "manifest ID %s isn't unique", item.ID)
+		}
+		itemsByID[item.ID] = item
+		if item.Href == "" {
+			c.errorf(opfPath, "manifest item %s has no href", item.ID)
+			continue
+		}
+		// Hrefs are decoded as epub.Read does
+		hrefPath, _ := book.DecodeHref(item.Href)
+		itemPath := book.AbsPath(opfPath, hrefPath)
+		itemPaths[item.ID] = itemPath
+		if inManifest[itemPath] {
+			c.errorf(opfPath, "manifest lists %s more than once", itemPath)
+		}
+		inManifest[itemPath] = true
+		if _, ok := c.files[itemPath]; !ok {
+			c.errorf(opfPath, "manifest item %s is missing from the book", itemPath)
+			continue
+		}
+		c.checkMediaType(itemPath, item.MediaType)
+		resource := book.Resource{ID: item.ID, Path: itemPath, MediaType: item.MediaType}
+		if linkingMediaTypes[item.MediaType] {
+			resource.Contents, _ = c.read(itemPath)
+		}
+		c.b.Resources = append(c.b.Resources, resource)
+		if hasWord(item.Properties, "nav") {
+			navItems = append(navItems, itemPath)
+		}
+	}
+	for _, f := range c.fileOrder {
+		name := f.Name
+		if name != "mimetype" && !strings.HasPrefix(name, "META-INF/") && !strings.HasSuffix(name, "/") && !inManifest[name] {
+			c.warnf(name, "isn't in the manifest")
+		}
+	}
+	if strings.HasPrefix(pack.Version, "3") && len(navItems) != 1 {
+		c.errorf(opfPath, "EPUB 3 books need one navigation document, found %d", len(navItems))
+	}
+
+	if len(pack.Spine.ItemRefs) == 0 {
+		c.errorf(opfPath, "spine is empty")
+	}
+	for _, itemRef := range pack.Spine.ItemRefs {
+		item, ok := itemsByID[itemRef.IDRef]
+		if !ok {
+			c.errorf(opfPath, "spine refers to missing manifest item %s", itemRef.IDRef)
+			continue
+		}
+		if !contentMediaTypes[item.MediaType] {
+			c.errorf(opfPath, "spine item %s has media type %s, which isn't a content document", item.ID, item.MediaType)
+		}
+	}
+
+	for _, item := range pack.Manifest.Items {
+		itemPath, ok := itemPaths[item.ID]
+		if ok && contentMediaTypes[item.MediaType] {
+			c.checkDocument(itemPath)
+		}
+	}
+
+	// Links in pages and stylesheets are checked as clean/links does, and so
+	// are those in the guide and NCX, which aren't resources of the book
+	problems := cleanLinks.Check(c.b)
+	var guideHrefs []string
+	for _, ref := range pack.Guide.Refs {
+		guideHrefs = append(guideHrefs, ref.Href)
+	}
+	problems = append(problems, cleanLinks.CheckHrefs(c.b, opfPath, guideHrefs)...)
+	if pack.Spine.Toc != "" {
+		tocItem, ok := itemsByID[pack.Spine.Toc]
+		if !ok || tocItem.MediaType != ncx.MediaType {
+			c.errorf(opfPath, "spine toc %s isn't an NCX manifest item", pack.Spine.Toc)
+		} else if ncxPath := itemPaths[tocItem.ID]; c.files[ncxPath] != nil {
+			problems = append(problems, cleanLinks.CheckHrefs(c.b, ncxPath, c.checkNCX(ncxPath))...)
+		}
+	} else if !strings.HasPrefix(pack.Version, "3") {
+		c.errorf(opfPath, "EPUB 2 books need a spine toc")
+	}
+	for _, problem := range problems {
+		if problem.Fix == "" {
+			c.errorf(problem.Path, "link %q is broken: %s", problem.Link, problem.Kind)
+		} else {
+			c.errorf(problem.Path, "link %q is broken: %s, and should be %q", problem.Link, problem.Kind, problem.Fix)
+		}
+	}
+}
+
+func (c *checker) checkMediaType(itemPath string, mediaType string) {
+	ext := strings.ToLower(path.Ext(itemPath))
+	plausible, ok := mediaTypesByExt[ext]
+	if !ok {
+		return
+	}
+	for _, p := range plausible {
+		if p == mediaType {
+			return
+		}
+	}
+	c.warnf(itemPath, "has media type %s, but its extension suggests %s", mediaType, plausible[0])
+}
+
+// Check that a document is well-formed XML with unique IDs
+func (c *checker) checkDocument(docPath string) {
+	contents, ok := c.read(docPath)
+	if !ok {
+		return
+	}
+	ids := map[string]bool{}
+	decoder := xml.NewDecoder(bytes.NewReader(contents))
+	decoder.Strict = true
+	// XHTML 1.1 documents may use the named entities of HTML
+	decoder.Entity = xml.HTMLEntity
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			c.errorf(docPath, "isn't well-formed: %s", err)
+			return
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, attr := range start.Attr {
+			if attr.Name.Local == "id" && (attr.Name.Space == "" || attr.Name.Space == xmlNS) {
+				if ids[attr.Value] {
+					c.errorf(docPath, "ID %s isn't unique", attr.Value)
+				}
+				ids[attr.Value] = true
+			}
+		}
+	}
+}
+
+// Check that the NCX is valid with unique IDs, returning its links
+func (c *checker) checkNCX(ncxPath string) []string {
+	contents, _ := c.read(ncxPath)
+	toc, err := ncx.Read(contents)
+	if err != nil {
+		c.errorf(ncxPath, "isn't valid: %s", err)
+		return nil
+	}
+	var hrefs []string
+	ids := map[string]bool{}
+	checkID := func(id string) {
+		if ids[id] {
+			c.errorf(ncxPath, "ID %s isn't unique", id)
+		}
+		ids[id] = true
+	}
+	var walk func(navPoints []ncx.NavPoint)
+	walk = func(navPoints []ncx.NavPoint) {
+		for _, np := range navPoints {
+			checkID(np.ID)
+			hrefs = append(hrefs, np.Content.Src)
+			walk(np.NavPoints)
+		}
+	}
+	walk(toc.NavPoints)
+	if toc.PageList != nil {
+		for _, pt := range toc.PageList.PageTargets {
+			checkID(pt.ID)
+			hrefs = append(hrefs, pt.Content.Src)
+		}
+	}
+	for _, navList := range toc.NavLists {
+		for _, nt := range navList.NavTargets {
+			checkID(nt.ID)
+			hrefs = append(hrefs, nt.Content.Src)
+		}
+	}
+	return hrefs
+}
+
+func hasWord(words string, word string) bool {
+	for _, w := range strings.Fields(words) {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package check
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/epub"
+	"github.com/asavoy/reprint/epub/epubtest"
+)
+
+const containerXML = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+const contentOPF = `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">urn:uuid:1</dc:identifier>
+  </metadata>
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="c1" href="Text/chapter%201.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c2" href="Text/2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="img" href="Images/a.png" media-type="image/jpeg"/>
+    <item id="css" href="Styles/main.css" media-type="text/css"/>
+  </manifest>
+  <spine toc="ncx">
+    <itemref idref="c1"/>
+    <itemref idref="c2"/>
+    <itemref idref="c3"/>
+  </spine>
+</package>`
+
+const tocNCX = `<?xml version="1.0"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <navMap>
+    <navPoint id="n1" playOrder="1"><navLabel><text>1</text></navLabel><content src="Text/chapter%201.xhtml#start"/></navPoint>
+    <navPoint id="n1" playOrder="2"><navLabel><text>2</text></navLabel><content src="Text/2.xhtml#gone"/></navPoint>
+  </navMap>
+</ncx>`
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reprint-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bookPath := epubtest.WriteZip(t, dir, []epubtest.File{
+		{Name: "META-INF/container.xml", Contents: containerXML, Method: zip.Deflate},
+		{Name: "mimetype", Contents: "application/epub+zip", Method: zip.Deflate},
+		{Name: "OEBPS/content.opf", Contents: contentOPF, Method: zip.Deflate},
+		{Name: "OEBPS/toc.ncx", Contents: tocNCX, Method: zip.Deflate},
+		{Name: "OEBPS/Text/chapter 1.xhtml", Method: zip.Deflate, Contents: `<html xmlns="http://www.w3.org/1999/xhtml"><body>
+<h1 id="start">One&nbsp;</h1><p id="start"><a href="2.xhtml#p">Two</a> <a href="2.xhtml#%70">Encoded</a> <a href="http://example.com/">Web</a>
+<img src="../Images/a.png"/><img src="../Images/b.png"/></p></body></html>`},
+		{Name: "OEBPS/Text/2.xhtml", Contents: `<html><body><p id="p">Two</body></html>`, Method: zip.Deflate},
+		{Name: "OEBPS/Images/a.png", Contents: "png", Method: zip.Store},
+		{Name: "OEBPS/Styles/main.css", Contents: "p { background: url(../Images/gone.png); }", Method: zip.Deflate},
+		{Name: "OEBPS/stray.css", Contents: "", Method: zip.Deflate},
+	})
+
+	got, err := File(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Finding{
+		{SeverityError, "mimetype", "must be the first file"},
+		{SeverityError, "mimetype", "must be stored without compression"},
+		{SeverityWarning, "OEBPS/Images/a.png", "has media type image/jpeg, but its extension suggests image/png"},
+		{SeverityWarning, "OEBPS/stray.css", "isn't in the manifest"},
+		{SeverityError, "OEBPS/content.opf", "spine refers to missing manifest item c3"},
+		{SeverityError, "OEBPS/Text/chapter 1.xhtml", "ID start isn't unique"},
+		{SeverityError, "OEBPS/Text/2.xhtml", "isn't well-formed: XML syntax error on line 1: element <p> closed by </body>"},
+		{SeverityError, "OEBPS/toc.ncx", "ID n1 isn't unique"},
+		{SeverityError, "OEBPS/Text/chapter 1.xhtml", `link "../Images/b.png" is broken: missing file`},
+		{SeverityError, "OEBPS/Styles/main.css", `link "../Images/gone.png" is broken: missing file`},
+		{SeverityError, "OEBPS/toc.ncx", `link "Text/2.xhtml#gone" is broken: missing fragment`},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+// Books written by reprint should pass
+func TestWrittenBook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reprint-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bookPath := filepath.Join(dir, "book.epub")
+	b := book.Book{
+		Metadata: book.Metadata{
+			Titles:     []book.Title{{Value: "A Sample Book"}},
+			Identifier: "urn:uuid:1",
+			Language:   "en",
+		},
+		Resources: []book.Resource{
+			{ID: "c1", Path: "text/1.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><body><h1 id="start">One</h1></body></html>`)},
+			{ID: "c2", Path: "text/C++ Primer.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><body><h1 id="two">Two</h1></body></html>`)},
+		},
+		SpineItems: []book.SpineItem{{ID: "c1", Linear: true}, {ID: "c2", Linear: true}},
+		TOCItems:   []book.TOCItem{{Label: "One", Href: "text/1.xhtml#start"}, {Label: "Two", Href: "text/C++ Primer.xhtml#two"}},
+		GuideItems: []book.GuideItem{{Type: book.GuideText, Href: "text/1.xhtml"}},
+	}
+	if err := epub.Write(bookPath, b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := File(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) > 0 {
+		t.Error("got findings:\n", got)
+	}
+}
+
+func TestFileEmptyIdentifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reprint-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bookPath := epubtest.WriteZip(t, dir, []epubtest.File{
+		{Name: "mimetype", Contents: "application/epub+zip", Method: zip.Store},
+		{Name: "META-INF/container.xml", Contents: containerXML, Method: zip.Deflate},
+		{Name: "OEBPS/content.opf", Method: zip.Deflate, Contents: `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="uid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid"> </dc:identifier>
+  </metadata>
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="c1" href="1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine toc="ncx"><itemref idref="c1"/></spine>
+</package>`},
+		{Name: "OEBPS/toc.ncx", Method: zip.Deflate, Contents: `<?xml version="1.0"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <navMap>
+    <navPoint id="n1" playOrder="1"><navLabel><text>1</text></navLabel><content src="1.xhtml"/></navPoint>
+  </navMap>
+</ncx>`},
+		{Name: "OEBPS/1.xhtml", Contents: `<html xmlns="http://www.w3.org/1999/xhtml"><body><p>One</p></body></html>`, Method: zip.Deflate},
+	})
+
+	got, err := File(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Finding{
+		{SeverityError, "OEBPS/content.opf", `unique identifier "uid" is empty`},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
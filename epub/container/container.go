@@ -14,8 +14,31 @@ type Container struct {
 }
 
 type RootFile struct {
-	FullPath  string `xml:"full-path,attr"`
-	MediaType string `xml:"media-type,attr"`
+	FullPath   string `xml:"full-path,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Layout     string `xml:"http://www.idpf.org/2013/rendition layout,attr,omitempty"`
+	Media      string `xml:"http://www.idpf.org/2013/rendition media,attr,omitempty"`
+	Language   string `xml:"http://www.idpf.org/2013/rendition language,attr,omitempty"`
+	AccessMode string `xml:"http://www.idpf.org/2013/rendition accessMode,attr,omitempty"`
+	Label      string `xml:"http://www.idpf.org/2013/rendition label,attr,omitempty"`
+}
+
+// A rendition property of the rootfile, by its attribute name without the
+// namespace, like "layout"
+func (rf RootFile) Property(name string) string {
+	switch name {
+	case "layout":
+		return rf.Layout
+	case "media":
+		return rf.Media
+	case "language":
+		return rf.Language
+	case "accessMode":
+		return rf.AccessMode
+	case "label":
+		return rf.Label
+	}
+	return ""
 }
 
 func Read(xmlBytes []byte) (Container, error) {
@@ -36,6 +36,34 @@ func TestRead(t *testing.T) {
 	}
 }
 
+func TestReadRenditions(t *testing.T) {
+	containerXML := []byte(
+		`<?xml version="1.0"?>
+		<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container"
+			xmlns:rendition="http://www.idpf.org/2013/rendition">
+			<rootfiles>
+				<rootfile full-path="reflow/content.opf" media-type="application/oebps-package+xml"
+					rendition:label="Text"/>
+				<rootfile full-path="fixed/content.opf" media-type="application/oebps-package+xml"
+					rendition:layout="pre-paginated" rendition:media="(min-width: 1024px)"/>
+			</rootfiles>
+		</container>`)
+	got, err := Read(containerXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RootFile{
+		{FullPath: "reflow/content.opf", MediaType: "application/oebps-package+xml", Label: "Text"},
+		{FullPath: "fixed/content.opf", MediaType: "application/oebps-package+xml", Layout: "pre-paginated", Media: "(min-width: 1024px)"},
+	}
+	if diff := cmp.Diff(want, got.RootFiles); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff("pre-paginated", got.RootFiles[1].Property("layout")); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
 func TestWrite(t *testing.T) {
 	container := Container{
 		Version: "1.0",
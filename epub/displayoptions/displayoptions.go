@@ -0,0 +1,61 @@
+package displayoptions
+
+import (
+	"encoding/xml"
+)
+
+var Path = "META-INF/com.apple.ibooks.display-options.xml"
+
+// XML structure of Apple's display options, which predate EPUB 3 rendition
+// properties
+type DisplayOptions struct {
+	XMLName   xml.Name   `xml:"display_options"`
+	Platforms []Platform `xml:"platform"`
+}
+
+type Platform struct {
+	Name    string   `xml:"name,attr"` // "*", "iphone" or "ipad"
+	Options []Option `xml:"option"`
+}
+
+type Option struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// The value of an option for all platforms, or for any platform when no
+// value is given for all
+func (d DisplayOptions) Option(name string) string {
+	value := ""
+	for _, platform := range d.Platforms {
+		for _, option := range platform.Options {
+			if option.Name != name {
+				continue
+			}
+			if platform.Name == "*" {
+				return option.Value
+			}
+			if value == "" {
+				value = option.Value
+			}
+		}
+	}
+	return value
+}
+
+func Read(xmlBytes []byte) (DisplayOptions, error) {
+	var displayOptions DisplayOptions
+	err := xml.Unmarshal(xmlBytes, &displayOptions)
+	if err != nil {
+		return DisplayOptions{}, err
+	}
+	return displayOptions, nil
+}
+
+func Write(displayOptions DisplayOptions) ([]byte, error) {
+	xmlBytes, err := xml.MarshalIndent(displayOptions, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(xml.Header + string(xmlBytes)), nil
+}
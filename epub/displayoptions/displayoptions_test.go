@@ -0,0 +1,52 @@
+package displayoptions
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRead(t *testing.T) {
+	xmlBytes := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<display_options>
+	<platform name="ipad">
+		<option name="open-to-spread">true</option>
+	</platform>
+	<platform name="*">
+		<option name="fixed-layout">true</option>
+		<option name="open-to-spread">false</option>
+	</platform>
+</display_options>`)
+	got, err := Read(xmlBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"fixed-layout":     "true",
+		"open-to-spread":   "false",
+		"specified-fonts":  "",
+		"orientation-lock": "",
+	} {
+		if diff := cmp.Diff(want, got.Option(name)); diff != "" {
+			t.Errorf("%s: got != want:\n%s", name, diff)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	got, err := Write(DisplayOptions{
+		Platforms: []Platform{{Name: "*", Options: []Option{{Name: "fixed-layout", Value: "true"}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<display_options>
+    <platform name="*">
+        <option name="fixed-layout">true</option>
+    </platform>
+</display_options>`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
@@ -0,0 +1,38 @@
+// Package epubtest writes book files for tests
+package epubtest
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A file in a book, compressed with Method, which defaults to zip.Store
+type File struct {
+	Name     string
+	Contents string
+	Method   uint16
+}
+
+// Write the files, in order, to book.epub in dir and return its path
+func WriteZip(t *testing.T, dir string, files []File) string {
+	zipPath := filepath.Join(dir, "book.epub")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for _, file := range files {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: file.Name, Method: file.Method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(file.Contents))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return zipPath
+}
@@ -0,0 +1,37 @@
+package epub
+
+// EPUB 2 guide types and their EPUB 3 landmark equivalents
+var guideLandmarks = []struct {
+	guideType string
+	landmark  string
+}{
+	{"cover", "cover"},
+	{"title-page", "titlepage"},
+	{"toc", "toc"},
+	{"text", "bodymatter"},
+	{"foreword", "foreword"},
+	{"preface", "preface"},
+	{"dedication", "dedication"},
+	{"epigraph", "epigraph"},
+	{"acknowledgements", "acknowledgments"},
+	{"copyright-page", "copyright-page"},
+	{"bibliography", "bibliography"},
+	{"glossary", "glossary"},
+	{"index", "index"},
+	{"loi", "loi"},
+	{"lot", "lot"},
+	{"notes", "endnotes"},
+	{"colophon", "colophon"},
+}
+
+var (
+	landmarkByGuideType = make(map[string]string)
+	guideTypeByLandmark = make(map[string]string)
+)
+
+func init() {
+	for _, gl := range guideLandmarks {
+		landmarkByGuideType[gl.guideType] = gl.landmark
+		guideTypeByLandmark[gl.landmark] = gl.guideType
+	}
+}
@@ -0,0 +1,106 @@
+package epub
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/epub/opf"
+)
+
+// Books are written as EPUB 3 packages. The landmarks, page-list and
+// rendition properties have no EPUB 2 equivalent, and EPUB 3 drops the
+// opf:scheme, opf:file-as, opf:role and opf:event attributes, so the
+// metadata they carried is written as refining metas instead. EPUB 2
+// reading systems still get the NCX and guide.
+const packageVersion = "3.0"
+
+// Identifiers other than the unique identifier are typed with EPUB 3
+// refinements, except ISBNs which are written as URNs
+func buildIdentifiers(identifierName string, uniqueID string, bookIdentifiers []book.Identifier) ([]opf.Identifier, []opf.Meta) {
+	identifiers := []opf.Identifier{
+		{ID: identifierName, Value: uniqueID},
+	}
+	var metas []opf.Meta
+	for i, identifier := range bookIdentifiers {
+		if strings.EqualFold(identifier.Scheme, "ISBN") {
+			identifiers = append(identifiers, opf.Identifier{Value: "urn:isbn:" + identifier.Value})
+			continue
+		}
+		ID := fmt.Sprintf("identifier%d", i+1)
+		identifiers = append(identifiers, opf.Identifier{ID: ID, Value: identifier.Value})
+		if identifier.Scheme != "" {
+			metas = append(metas, opf.Meta{Refines: "#" + ID, Property: "identifier-type", Value: identifier.Scheme})
+		}
+	}
+	return identifiers, metas
+}
+
+func buildCreators(bookCreators []book.Creator) ([]opf.Creator, []opf.Meta) {
+	var creators []opf.Creator
+	var metas []opf.Meta
+	for i, creator := range bookCreators {
+		ID := fmt.Sprintf("creator%d", i+1)
+		creators = append(creators, opf.Creator{ID: ID, Value: creator.Name})
+		if creator.FileAs != "" {
+			metas = append(metas, opf.Meta{Refines: "#" + ID, Property: "file-as", Value: creator.FileAs})
+		}
+		if creator.Role != "" {
+			metas = append(metas, opf.Meta{Refines: "#" + ID, Property: "role", Scheme: "marc:relators", Value: creator.Role})
+		}
+	}
+	return creators, metas
+}
+
+// EPUB 3 has a single date for publication, and records the creation date
+// as a meta. Other dates, including further publication dates and the book's
+// own modification dates, are dcterms:date metas refined with their event,
+// so none are lost. The required dcterms:modified meta is when the book is
+// written, as given by modified.
+func buildDates(bookDates []book.Date, modified time.Time) ([]opf.Date, []opf.Meta) {
+	var dates []opf.Date
+	var metas []opf.Meta
+	hasCreated := false
+	for i, bookDate := range bookDates {
+		switch {
+		case (bookDate.Event == "" || bookDate.Event == "publication") && len(dates) == 0:
+			dates = append(dates, opf.Date{Value: bookDate.Value})
+		case bookDate.Event == "creation" && !hasCreated:
+			metas = append(metas, opf.Meta{Property: "dcterms:created", Value: bookDate.Value})
+			hasCreated = true
+		default:
+			ID := fmt.Sprintf("date%d", i+1)
+			event := bookDate.Event
+			if event == "" {
+				event = "publication"
+			}
+			metas = append(metas,
+				opf.Meta{ID: ID, Property: "dcterms:date", Value: bookDate.Value},
+				opf.Meta{Refines: "#" + ID, Property: dateEventProperty, Value: event},
+			)
+		}
+	}
+	metas = append(metas, opf.Meta{
+		Property: "dcterms:modified",
+		Value:    modified.UTC().Format("2006-01-02T15:04:05Z"),
+	})
+	return dates, metas
+}
+
+// Dates with events that EPUB 3 has no property for are refined with a
+// property of our own, declared with a package prefix
+const (
+	reprintPrefix     = "reprint: https://github.com/asavoy/reprint#"
+	dateEventProperty = "reprint:event"
+)
+
+// Whether any of the metas need the reprint prefix declared on the package
+func usesReprintPrefix(metas []opf.Meta) bool {
+	for _, meta := range metas {
+		if strings.HasPrefix(meta.Property, "reprint:") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package nav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	DocType    = "<!DOCTYPE html>\n"
+	Properties = "nav"
+	EPUBNS     = "http://www.idpf.org/2007/ops"
+)
+
+// XML structure of an EPUB 3 navigation document, e.g. nav.xhtml. Attributes
+// are written with the conventional epub: prefix, which some reading systems
+// match literally.
+type Nav struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/1999/xhtml html"`
+	EPUBNS  string       `xml:"xmlns:epub,attr"`
+	Title   string       `xml:"head>title"`
+	Navs    []NavElement `xml:"body>nav"`
+}
+
+type NavElement struct {
+	Type    string `xml:"epub:type,attr"`
+	Hidden  string `xml:"hidden,attr,omitempty"`
+	Heading string `xml:"h1,omitempty"`
+	List    List   `xml:"ol"`
+}
+
+type List struct {
+	Items []Item `xml:"li"`
+}
+
+type Item struct {
+	Link Link  `xml:"a"`
+	List *List `xml:"ol,omitempty"`
+}
+
+type Link struct {
+	Type  string `xml:"epub:type,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Label string `xml:",chardata"`
+}
+
+// Find the nav element of the given type, e.g. toc or landmarks
+func (n Nav) Find(navType string) (NavElement, bool) {
+	for _, navElement := range n.Navs {
+		for _, t := range strings.Fields(navElement.Type) {
+			if t == navType {
+				return navElement, true
+			}
+		}
+	}
+	return NavElement{}, false
+}
+
+// Navigation documents in the wild nest nav elements anywhere in the body,
+// and use the epub: prefix for the ops namespace, so they're read as HTML
+func Read(xhtmlBytes []byte) (Nav, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(xhtmlBytes))
+	if err != nil {
+		return Nav{}, err
+	}
+	n := Nav{
+		EPUBNS: EPUBNS,
+		Title:  strings.TrimSpace(doc.Find("head title").Text()),
+	}
+	doc.Find("nav").Each(func(_ int, s *goquery.Selection) {
+		n.Navs = append(n.Navs, NavElement{
+			Type:    s.AttrOr("epub:type", ""),
+			Hidden:  s.AttrOr("hidden", ""),
+			Heading: strings.TrimSpace(s.ChildrenFiltered("h1, h2, h3, h4, h5, h6").First().Text()),
+			List:    readList(s.ChildrenFiltered("ol").First()),
+		})
+	})
+	return n, nil
+}
+
+func readList(ol *goquery.Selection) List {
+	var list List
+	ol.ChildrenFiltered("li").Each(func(_ int, li *goquery.Selection) {
+		// Headings without links are spans
+		link := li.ChildrenFiltered("a, span").First()
+		item := Item{
+			Link: Link{
+				Type:  link.AttrOr("epub:type", ""),
+				Href:  link.AttrOr("href", ""),
+				Label: strings.Join(strings.Fields(link.Text()), " "),
+			},
+		}
+		if childOL := li.ChildrenFiltered("ol"); childOL.Length() > 0 {
+			childList := readList(childOL.First())
+			item.List = &childList
+		}
+		list.Items = append(list.Items, item)
+	})
+	return list
+}
+
+func Write(n Nav) ([]byte, error) {
+	xmlBytes, err := xml.MarshalIndent(n, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(xml.Header + DocType + string(xmlBytes)), nil
+}
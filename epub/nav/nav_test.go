@@ -0,0 +1,136 @@
+package nav
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRead(t *testing.T) {
+	navXHTML := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>A Sample Book</title></head>
+<body>
+    <section>
+        <nav epub:type="toc" id="toc">
+            <h2>Contents</h2>
+            <ol>
+                <li><a href="1.xhtml">Foreword</a></li>
+                <li>
+                    <span>Main</span>
+                    <ol>
+                        <li><a href="3.xhtml#start">Chapter
+                            1</a></li>
+                    </ol>
+                </li>
+            </ol>
+        </nav>
+    </section>
+    <nav epub:type="landmarks" hidden="">
+        <ol>
+            <li><a epub:type="bodymatter" href="3.xhtml#start">Start</a></li>
+        </ol>
+    </nav>
+</body>
+</html>`)
+	got, err := Read(navXHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Nav{
+		EPUBNS: EPUBNS,
+		Title:  "A Sample Book",
+		Navs: []NavElement{
+			{
+				Type:    "toc",
+				Heading: "Contents",
+				List: List{Items: []Item{
+					{Link: Link{Href: "1.xhtml", Label: "Foreword"}},
+					{
+						Link: Link{Label: "Main"},
+						List: &List{Items: []Item{
+							{Link: Link{Href: "3.xhtml#start", Label: "Chapter 1"}},
+						}},
+					},
+				}},
+			},
+			{
+				Type: "landmarks",
+				List: List{Items: []Item{
+					{Link: Link{Type: "bodymatter", Href: "3.xhtml#start", Label: "Start"}},
+				}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if _, ok := got.Find("landmarks"); !ok {
+		t.Error("can't find landmarks")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	n := Nav{
+		EPUBNS: EPUBNS,
+		Title:  "A Sample Book",
+		Navs: []NavElement{
+			{
+				Type:    "toc",
+				Heading: "Contents",
+				List: List{Items: []Item{
+					{
+						Link: Link{Href: "1.xhtml", Label: "Part 1"},
+						List: &List{Items: []Item{
+							{Link: Link{Href: "1.xhtml#c1", Label: "Chapter 1"}},
+						}},
+					},
+				}},
+			},
+			{
+				Type:   "landmarks",
+				Hidden: "hidden",
+				List: List{Items: []Item{
+					{Link: Link{Type: "cover", Href: "cover.xhtml", Label: "Cover"}},
+				}},
+			},
+		},
+	}
+	got, err := Write(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+    <head>
+        <title>A Sample Book</title>
+    </head>
+    <body>
+        <nav epub:type="toc">
+            <h1>Contents</h1>
+            <ol>
+                <li>
+                    <a href="1.xhtml">Part 1</a>
+                    <ol>
+                        <li>
+                            <a href="1.xhtml#c1">Chapter 1</a>
+                        </li>
+                    </ol>
+                </li>
+            </ol>
+        </nav>
+        <nav epub:type="landmarks" hidden="hidden">
+            <ol>
+                <li>
+                    <a epub:type="cover" href="cover.xhtml">Cover</a>
+                </li>
+            </ol>
+        </nav>
+    </body>
+</html>`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
@@ -18,6 +18,8 @@ type NCX struct {
 	Title     string     `xml:"docTitle>text"`
 	Author    string     `xml:"docAuthor>text"`
 	NavPoints []NavPoint `xml:"navMap>navPoint"`
+	PageList  *PageList  `xml:"pageList,omitempty"`
+	NavLists  []NavList  `xml:"navList"`
 }
 
 type Meta struct {
@@ -36,6 +38,38 @@ type Content struct {
 	Src string `xml:"src,attr"`
 }
 
+// Print page numbers
+type PageList struct {
+	ID          string       `xml:"id,attr,omitempty"`
+	Label       string       `xml:"navLabel>text,omitempty"`
+	PageTargets []PageTarget `xml:"pageTarget"`
+}
+
+type PageTarget struct {
+	ID        string  `xml:"id,attr"`
+	Type      string  `xml:"type,attr"` // front, normal or special
+	Value     string  `xml:"value,attr,omitempty"`
+	PlayOrder string  `xml:"playOrder,attr"`
+	Label     string  `xml:"navLabel>text"`
+	Content   Content `xml:"content"`
+}
+
+// Lists of other targets, such as illustrations or tables
+type NavList struct {
+	ID         string      `xml:"id,attr,omitempty"`
+	Class      string      `xml:"class,attr,omitempty"`
+	Label      string      `xml:"navLabel>text"`
+	NavTargets []NavTarget `xml:"navTarget"`
+}
+
+type NavTarget struct {
+	ID        string  `xml:"id,attr"`
+	Value     string  `xml:"value,attr,omitempty"`
+	PlayOrder string  `xml:"playOrder,attr"`
+	Label     string  `xml:"navLabel>text"`
+	Content   Content `xml:"content"`
+}
+
 func Read(xmlBytes []byte) (NCX, error) {
 	var ncx NCX
 	err := xml.Unmarshal(xmlBytes, &ncx)
@@ -194,3 +194,92 @@ func TestWrite(t *testing.T) {
 		t.Error("got != want:\n", diff)
 	}
 }
+
+func TestPageListAndNavList(t *testing.T) {
+	tocNCX := []byte(
+		`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE ncx PUBLIC "-//NISO//DTD ncx 2005-1//EN" "http://www.daisy.org/z3986/2005/ncx-2005-1.dtd">
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+    <head>
+        <meta name="dtb:totalPageCount" content="2"></meta>
+        <meta name="dtb:maxPageNumber" content="1"></meta>
+    </head>
+    <docTitle>
+        <text>A Sample Book</text>
+    </docTitle>
+    <docAuthor>
+        <text>John Smith</text>
+    </docAuthor>
+    <navMap>
+        <navPoint id="p1" playOrder="1">
+            <navLabel>
+                <text>Chapter 1</text>
+            </navLabel>
+            <content src="1.xhtml"></content>
+        </navPoint>
+    </navMap>
+    <pageList>
+        <navLabel>
+            <text>Pages</text>
+        </navLabel>
+        <pageTarget id="page-iv" type="front" playOrder="2">
+            <navLabel>
+                <text>iv</text>
+            </navLabel>
+            <content src="1.xhtml#page-iv"></content>
+        </pageTarget>
+        <pageTarget id="page-1" type="normal" value="1" playOrder="3">
+            <navLabel>
+                <text>1</text>
+            </navLabel>
+            <content src="1.xhtml#page-1"></content>
+        </pageTarget>
+    </pageList>
+    <navList class="lot">
+        <navLabel>
+            <text>Tables</text>
+        </navLabel>
+        <navTarget id="table-1" playOrder="4">
+            <navLabel>
+                <text>Table 1</text>
+            </navLabel>
+            <content src="1.xhtml#table-1"></content>
+        </navTarget>
+    </navList>
+</ncx>`)
+	got, err := Read(tocNCX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPageList := &PageList{
+		Label: "Pages",
+		PageTargets: []PageTarget{
+			{ID: "page-iv", Type: "front", PlayOrder: "2", Label: "iv", Content: Content{Src: "1.xhtml#page-iv"}},
+			{ID: "page-1", Type: "normal", Value: "1", PlayOrder: "3", Label: "1", Content: Content{Src: "1.xhtml#page-1"}},
+		},
+	}
+	wantNavLists := []NavList{
+		{
+			Class: "lot",
+			Label: "Tables",
+			NavTargets: []NavTarget{
+				{ID: "table-1", PlayOrder: "4", Label: "Table 1", Content: Content{Src: "1.xhtml#table-1"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(wantPageList, got.PageList); diff != "" {
+		t.Error("pageList got != want:\n", diff)
+	}
+	if diff := cmp.Diff(wantNavLists, got.NavLists); diff != "" {
+		t.Error("navLists got != want:\n", diff)
+	}
+
+	// Writing what was read should give the same document
+	written, err := Write(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(tocNCX), string(written)); diff != "" {
+		t.Error("written != read:\n", diff)
+	}
+}
@@ -8,6 +8,7 @@ import (
 type Package struct {
 	XMLName          xml.Name `xml:"http://www.idpf.org/2007/opf package"`
 	Version          string   `xml:"version,attr"`
+	Prefix           string   `xml:"prefix,attr,omitempty"`
 	UniqueIdentifier string   `xml:"unique-identifier,attr"`
 	Metadata         Metadata `xml:"http://www.idpf.org/2007/opf metadata"`
 	Manifest         Manifest `xml:"http://www.idpf.org/2007/opf manifest"`
@@ -17,30 +18,50 @@ type Package struct {
 
 type Metadata struct {
 	XMLName     xml.Name     `xml:"http://www.idpf.org/2007/opf metadata"`
-	Title       string       `xml:"http://purl.org/dc/elements/1.1/ title"`
+	Titles      []Title      `xml:"http://purl.org/dc/elements/1.1/ title"`
 	Identifiers []Identifier `xml:"http://purl.org/dc/elements/1.1/ identifier"`
-	Creators    []string     `xml:"http://purl.org/dc/elements/1.1/ creator"`
-	Publisher   string       `xml:"http://purl.org/dc/elements/1.1/ publisher"`
+	Creators    []Creator    `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Publisher   string       `xml:"http://purl.org/dc/elements/1.1/ publisher,omitempty"`
 	Language    string       `xml:"http://purl.org/dc/elements/1.1/ language"`
 	Subjects    []string     `xml:"http://purl.org/dc/elements/1.1/ subject"`
-	Rights      string       `xml:"http://purl.org/dc/elements/1.1/ rights"`
-	Source      string       `xml:"http://purl.org/dc/elements/1.1/ source"`
+	Description string       `xml:"http://purl.org/dc/elements/1.1/ description,omitempty"`
+	Rights      string       `xml:"http://purl.org/dc/elements/1.1/ rights,omitempty"`
+	Source      string       `xml:"http://purl.org/dc/elements/1.1/ source,omitempty"`
 	Dates       []Date       `xml:"http://purl.org/dc/elements/1.1/ date"`
 	Metas       []Meta       `xml:"http://www.idpf.org/2007/opf meta"`
 }
 
+// Both EPUB 2 (name/content) and EPUB 3 (property/refines) forms
 type Meta struct {
-	Name    string `xml:"name,attr"`
-	Content string `xml:"content,attr"`
+	ID       string `xml:"id,attr,omitempty"`
+	Name     string `xml:"name,attr,omitempty"`
+	Content  string `xml:"content,attr,omitempty"`
+	Refines  string `xml:"refines,attr,omitempty"`
+	Property string `xml:"property,attr,omitempty"`
+	Scheme   string `xml:"scheme,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+type Title struct {
+	ID    string `xml:"id,attr,omitempty"`
+	Value string `xml:",chardata"`
 }
 
 type Identifier struct {
-	ID    string `xml:"id,attr"`
-	Value string `xml:",innerxml"`
+	ID     string `xml:"id,attr,omitempty"`
+	Scheme string `xml:"http://www.idpf.org/2007/opf scheme,attr,omitempty"`
+	Value  string `xml:",innerxml"`
+}
+
+type Creator struct {
+	ID     string `xml:"id,attr,omitempty"`
+	FileAs string `xml:"http://www.idpf.org/2007/opf file-as,attr,omitempty"`
+	Role   string `xml:"http://www.idpf.org/2007/opf role,attr,omitempty"`
+	Value  string `xml:",chardata"`
 }
 
 type Date struct {
-	Event string `xml:"event,attr"`
+	Event string `xml:"http://www.idpf.org/2007/opf event,attr,omitempty"`
 	Value string `xml:",innerxml"`
 }
 
@@ -50,20 +71,24 @@ type Manifest struct {
 }
 
 type ManifestItem struct {
-	ID        string `xml:"id,attr"`
-	Href      string `xml:"href,attr"`
-	MediaType string `xml:"media-type,attr"`
+	ID           string `xml:"id,attr"`
+	Href         string `xml:"href,attr"`
+	MediaType    string `xml:"media-type,attr"`
+	Properties   string `xml:"properties,attr,omitempty"`
+	MediaOverlay string `xml:"media-overlay,attr,omitempty"`
 }
 
 type Spine struct {
-	XMLName  xml.Name       `xml:"http://www.idpf.org/2007/opf spine"`
-	Toc      string         `xml:"toc,attr"`
-	ItemRefs []SpineItemRef `xml:"http://www.idpf.org/2007/opf itemref"`
+	XMLName                  xml.Name       `xml:"http://www.idpf.org/2007/opf spine"`
+	Toc                      string         `xml:"toc,attr"`
+	PageProgressionDirection string         `xml:"page-progression-direction,attr,omitempty"`
+	ItemRefs                 []SpineItemRef `xml:"http://www.idpf.org/2007/opf itemref"`
 }
 
 type SpineItemRef struct {
-	IDRef  string `xml:"idref,attr"`
-	Linear string `xml:"linear,attr"`
+	IDRef      string `xml:"idref,attr"`
+	Linear     string `xml:"linear,attr"`
+	Properties string `xml:"properties,attr,omitempty"`
 }
 
 type Guide struct {
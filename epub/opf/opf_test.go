@@ -56,11 +56,13 @@ func TestRead(t *testing.T) {
 		Version:          "2.0",
 		Metadata: Metadata{
 			XMLName: xml.Name{Space: "http://www.idpf.org/2007/opf", Local: "metadata"},
-			Title:   "The Count of Monte Cristo, Illustrated",
+			Titles: []Title{
+				{Value: "The Count of Monte Cristo, Illustrated"},
+			},
 			Identifiers: []Identifier{
-				{ID: "id", Value: "http://www.gutenberg.org/ebooks/1184"},
+				{ID: "id", Scheme: "URI", Value: "http://www.gutenberg.org/ebooks/1184"},
 			},
-			Creators:  []string{"Alexandre Dumas"},
+			Creators:  []Creator{{FileAs: "Dumas, Alexandre", Value: "Alexandre Dumas"}},
 			Publisher: "",
 			Language:  "en",
 			Subjects:  []string{"Historical fiction", "Adventure stories"},
@@ -115,11 +117,13 @@ func TestWrite(t *testing.T) {
 		UniqueIdentifier: "id",
 		Version:          "2.0",
 		Metadata: Metadata{
-			Title: "The Count of Monte Cristo, Illustrated",
+			Titles: []Title{
+				{Value: "The Count of Monte Cristo, Illustrated"},
+			},
 			Identifiers: []Identifier{
-				{ID: "id", Value: "http://www.gutenberg.org/ebooks/1184"},
+				{ID: "id", Scheme: "URI", Value: "http://www.gutenberg.org/ebooks/1184"},
 			},
-			Creators:  []string{"Alexandre Dumas"},
+			Creators:  []Creator{{FileAs: "Dumas, Alexandre", Value: "Alexandre Dumas"}},
 			Publisher: "",
 			Language:  "en",
 			Subjects:  []string{"Historical fiction", "Adventure stories"},
@@ -170,16 +174,15 @@ func TestWrite(t *testing.T) {
 <package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="id">
     <metadata xmlns="http://www.idpf.org/2007/opf">
         <title xmlns="http://purl.org/dc/elements/1.1/">The Count of Monte Cristo, Illustrated</title>
-        <identifier xmlns="http://purl.org/dc/elements/1.1/" id="id">http://www.gutenberg.org/ebooks/1184</identifier>
-        <creator xmlns="http://purl.org/dc/elements/1.1/">Alexandre Dumas</creator>
-        <publisher xmlns="http://purl.org/dc/elements/1.1/"></publisher>
+        <identifier xmlns="http://purl.org/dc/elements/1.1/" id="id" xmlns:opf="http://www.idpf.org/2007/opf" opf:scheme="URI">http://www.gutenberg.org/ebooks/1184</identifier>
+        <creator xmlns="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf" opf:file-as="Dumas, Alexandre">Alexandre Dumas</creator>
         <language xmlns="http://purl.org/dc/elements/1.1/">en</language>
         <subject xmlns="http://purl.org/dc/elements/1.1/">Historical fiction</subject>
         <subject xmlns="http://purl.org/dc/elements/1.1/">Adventure stories</subject>
         <rights xmlns="http://purl.org/dc/elements/1.1/">Public domain</rights>
         <source xmlns="http://purl.org/dc/elements/1.1/">http://www.gutenberg.org/files/1184/1184-h/1184-h.htm</source>
-        <date xmlns="http://purl.org/dc/elements/1.1/" event="publication">1998-01-01</date>
-        <date xmlns="http://purl.org/dc/elements/1.1/" event="conversion">2019-05-31T14:42:00.544171+00:00</date>
+        <date xmlns="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf" opf:event="publication">1998-01-01</date>
+        <date xmlns="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf" opf:event="conversion">2019-05-31T14:42:00.544171+00:00</date>
         <meta xmlns="http://www.idpf.org/2007/opf" name="cover" content="item1"></meta>
         <meta xmlns="http://www.idpf.org/2007/opf" name="calibre:timestamp" content="2017-10-10T20:22:18.989147+00:00"></meta>
     </metadata>
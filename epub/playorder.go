@@ -0,0 +1,181 @@
+package epub
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/epub/ncx"
+)
+
+// An entry of the NCX navMap, pageList or a navList
+type ncxTarget struct {
+	kind      string
+	id        *string
+	playOrder *string
+	label     string
+	src       string
+}
+
+// Where a target is in reading order: its document's index in the spine, and
+// the index of its fragment among the elements with IDs in that document
+type readingPosition struct {
+	spineIndex int
+	idIndex    int
+}
+
+// Renumber playOrder in reading order, giving entries with the same target
+// the same playOrder, and replace invalid or duplicate IDs. Returns warnings
+// for entries that target missing resources or fragments.
+func renumberNCX(toc *ncx.NCX, b book.Book) []string {
+	targets := collectNCXTargets(toc)
+	assignNCXIDs(targets)
+
+	spineIndexes := map[string]int{}
+	for i, spineItem := range b.SpineItems {
+		for _, r := range b.Resources {
+			if r.ID == spineItem.ID {
+				spineIndexes[r.Path] = i
+			}
+		}
+	}
+
+	var warnings []string
+	docIDs := map[string]map[string]int{}
+	positions := map[string]readingPosition{}
+	var srcs []string
+	for _, target := range targets {
+		if _, ok := positions[target.src]; ok {
+			continue
+		}
+		srcs = append(srcs, target.src)
+		// Sources are written from the root of the book, like the NCX
+		resourcePath, fragment := book.DecodeHref(target.src)
+
+		// Targets outside the spine are put after it
+		position := readingPosition{spineIndex: len(b.SpineItems), idIndex: -1}
+		if i, ok := spineIndexes[resourcePath]; ok {
+			position.spineIndex = i
+		}
+		resource, err := b.GetResource(resourcePath)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s %q links to missing resource %s", target.kind, target.label, resourcePath))
+		} else if fragment != "" {
+			ids, ok := docIDs[resourcePath]
+			if !ok {
+				ids = elementIDs(resource.Contents)
+				docIDs[resourcePath] = ids
+			}
+			if i, ok := ids[fragment]; ok {
+				position.idIndex = i
+			} else {
+				warnings = append(warnings, fmt.Sprintf("%s %q links to missing fragment %s", target.kind, target.label, target.src))
+			}
+		}
+		positions[target.src] = position
+	}
+
+	sort.SliceStable(srcs, func(i, j int) bool {
+		x, y := positions[srcs[i]], positions[srcs[j]]
+		if x.spineIndex != y.spineIndex {
+			return x.spineIndex < y.spineIndex
+		}
+		return x.idIndex < y.idIndex
+	})
+	playOrders := map[string]int{}
+	for i, src := range srcs {
+		playOrders[src] = i + 1
+	}
+	for _, target := range targets {
+		*target.playOrder = fmt.Sprintf("%d", playOrders[target.src])
+	}
+	return warnings
+}
+
+func collectNCXTargets(toc *ncx.NCX) []ncxTarget {
+	var targets []ncxTarget
+	var walk func(navPoints []ncx.NavPoint)
+	walk = func(navPoints []ncx.NavPoint) {
+		for i := range navPoints {
+			np := &navPoints[i]
+			targets = append(targets, ncxTarget{"navPoint", &np.ID, &np.PlayOrder, np.Label, np.Content.Src})
+			walk(np.NavPoints)
+		}
+	}
+	walk(toc.NavPoints)
+	if toc.PageList != nil {
+		for i := range toc.PageList.PageTargets {
+			pt := &toc.PageList.PageTargets[i]
+			targets = append(targets, ncxTarget{"pageTarget", &pt.ID, &pt.PlayOrder, pt.Label, pt.Content.Src})
+		}
+	}
+	for i := range toc.NavLists {
+		for j := range toc.NavLists[i].NavTargets {
+			nt := &toc.NavLists[i].NavTargets[j]
+			targets = append(targets, ncxTarget{"navTarget", &nt.ID, &nt.PlayOrder, nt.Label, nt.Content.Src})
+		}
+	}
+	return targets
+}
+
+// Keep the first use of each valid ID, and number the rest by kind
+func assignNCXIDs(targets []ncxTarget) {
+	used := map[string]bool{}
+	var needIDs []ncxTarget
+	for _, target := range targets {
+		if validXMLID(*target.id) && !used[*target.id] {
+			used[*target.id] = true
+		} else {
+			needIDs = append(needIDs, target)
+		}
+	}
+	counts := map[string]int{}
+	for _, target := range needIDs {
+		for {
+			counts[target.kind]++
+			id := fmt.Sprintf("%s-%d", target.kind, counts[target.kind])
+			if !used[id] {
+				used[id] = true
+				*target.id = id
+				break
+			}
+		}
+	}
+}
+
+// Whether id is an XML NCName, as required of ID attributes
+func validXMLID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for i, r := range id {
+		if unicode.IsLetter(r) || r == '_' {
+			continue
+		}
+		if i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Index of each element ID in document order
+func elementIDs(contents []byte) map[string]int {
+	ids := map[string]int{}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(contents))
+	if err != nil {
+		return ids
+	}
+	doc.Find("[id]").Each(func(i int, s *goquery.Selection) {
+		id := s.AttrOr("id", "")
+		if _, ok := ids[id]; !ok {
+			ids[id] = i
+		}
+	})
+	return ids
+}
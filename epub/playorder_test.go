@@ -0,0 +1,80 @@
+package epub
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/epub/ncx"
+)
+
+func TestRenumberNCX(t *testing.T) {
+	b := book.Book{
+		Resources: []book.Resource{
+			{ID: "c1", Path: "text/1.xhtml", Contents: []byte(`<html><body><h1 id="start">One</h1><p id="p1">Text</p></body></html>`)},
+			{ID: "c2", Path: "text/2.xhtml", Contents: []byte(`<html><body><h1>Two</h1></body></html>`)},
+		},
+		SpineItems: []book.SpineItem{{ID: "c1", Linear: true}, {ID: "c2", Linear: true}},
+	}
+	toc := ncx.NCX{
+		NavPoints: []ncx.NavPoint{
+			{ID: "1st", PlayOrder: "7", Label: "Chapter 2", Content: ncx.Content{Src: "text/2.xhtml"}},
+			{ID: "dup", PlayOrder: "7", Label: "Chapter 1", Content: ncx.Content{Src: "text/1.xhtml#start"}, NavPoints: []ncx.NavPoint{
+				{ID: "dup", PlayOrder: "2", Label: "Part", Content: ncx.Content{Src: "text/1.xhtml#p1"}},
+				{ID: "navPoint-1", PlayOrder: "3", Label: "Again", Content: ncx.Content{Src: "text/1.xhtml#start"}},
+			}},
+			{ID: "gone", PlayOrder: "9", Label: "Gone", Content: ncx.Content{Src: "text/3.xhtml"}},
+			{ID: "lost", PlayOrder: "9", Label: "Lost", Content: ncx.Content{Src: "text/2.xhtml#nowhere"}},
+		},
+		PageList: &ncx.PageList{
+			PageTargets: []ncx.PageTarget{
+				{ID: "page1", Label: "1", Content: ncx.Content{Src: "text/1.xhtml#p1"}},
+			},
+		},
+	}
+	gotWarnings := renumberNCX(&toc, b)
+	want := ncx.NCX{
+		NavPoints: []ncx.NavPoint{
+			{ID: "navPoint-2", PlayOrder: "3", Label: "Chapter 2", Content: ncx.Content{Src: "text/2.xhtml"}},
+			{ID: "dup", PlayOrder: "1", Label: "Chapter 1", Content: ncx.Content{Src: "text/1.xhtml#start"}, NavPoints: []ncx.NavPoint{
+				{ID: "navPoint-3", PlayOrder: "2", Label: "Part", Content: ncx.Content{Src: "text/1.xhtml#p1"}},
+				{ID: "navPoint-1", PlayOrder: "1", Label: "Again", Content: ncx.Content{Src: "text/1.xhtml#start"}},
+			}},
+			{ID: "gone", PlayOrder: "5", Label: "Gone", Content: ncx.Content{Src: "text/3.xhtml"}},
+			{ID: "lost", PlayOrder: "4", Label: "Lost", Content: ncx.Content{Src: "text/2.xhtml#nowhere"}},
+		},
+		PageList: &ncx.PageList{
+			PageTargets: []ncx.PageTarget{
+				{ID: "page1", PlayOrder: "2", Label: "1", Content: ncx.Content{Src: "text/1.xhtml#p1"}},
+			},
+		},
+	}
+	wantWarnings := []string{
+		`navPoint "Gone" links to missing resource text/3.xhtml`,
+		`navPoint "Lost" links to missing fragment text/2.xhtml#nowhere`,
+	}
+	if diff := cmp.Diff(want, toc); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff(wantWarnings, gotWarnings); diff != "" {
+		t.Error("warnings got != want:\n", diff)
+	}
+}
+
+func TestValidXMLID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"navPoint-1": true,
+		"_p.2":       true,
+		"chapitre-é": true,
+		"":           false,
+		"1":          false,
+		"-a":         false,
+		"a b":        false,
+		"a:b":        false,
+	} {
+		if got := validXMLID(id); got != want {
+			t.Errorf("validXMLID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -5,57 +5,267 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/url"
-	"path"
 	"strconv"
+	"strings"
 
 	"github.com/asavoy/reprint/book"
 	"github.com/asavoy/reprint/epub/container"
+	"github.com/asavoy/reprint/epub/displayoptions"
+	"github.com/asavoy/reprint/epub/nav"
 	"github.com/asavoy/reprint/epub/ncx"
 	"github.com/asavoy/reprint/epub/opf"
 )
 
-// Read an EPUBv2 format book
+// Which rendition to read from a book with several, as some ship a
+// reflowable and a fixed layout rendition together
+type Rendition struct {
+	// Position among the renditions, used when Properties is empty
+	Index int
+	// Rendition properties the rendition must have, like "layout":
+	// "pre-paginated". The first rendition that has them all is read.
+	Properties map[string]string
+}
+
+// Read an EPUB 2 or EPUB 3 format book, or the first rendition of a book with
+// several. The TOC and page list are read from the navigation document when
+// there's no NCX, and the landmarks when there's no guide.
 func Read(filepath string) (book.Book, error) {
+	return ReadRendition(filepath, Rendition{})
+}
+
+// The renditions of a book, which are the rootfiles of its container that
+// are packages
+func Renditions(filepath string) ([]container.RootFile, error) {
+	r, err := zip.OpenReader(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readRenditions(r)
+}
+
+// Read one rendition of a book. Only that rendition is kept when the book
+// is written.
+func ReadRendition(filepath string, rendition Rendition) (book.Book, error) {
 	r, err := zip.OpenReader(filepath)
 	if err != nil {
 		return book.Book{}, err
 	}
 	defer r.Close()
 
-	ctr, err := container.Read(readFile(r, container.Path))
+	renditions, err := readRenditions(r)
 	if err != nil {
 		return book.Book{}, err
 	}
-	opfPath := ctr.RootFiles[0].FullPath
-	pack, err := opf.Read(readFile(r, opfPath))
+	rootFile, err := chooseRendition(renditions, rendition)
 	if err != nil {
 		return book.Book{}, err
 	}
-	fmt.Println(pack.Metadata.Title)
-
-	tocResource, err := parseTOCResource(pack.Manifest.Items, r, opfPath)
+	opfPath := rootFile.FullPath
+	contents, err := readFile(r, opfPath)
 	if err != nil {
-		return book.Book{}, nil
+		return book.Book{}, err
 	}
-	tocNCX, err := ncx.Read(tocResource.Contents)
+	pack, err := opf.Read(contents)
 	if err != nil {
 		return book.Book{}, err
 	}
-	resources, err := parseResources(pack.Manifest.Items, r, opfPath)
+	resources, err := parseResources(pack.Manifest.Items, pack.Spine.ItemRefs, r, opfPath)
 	if err != nil {
 		return book.Book{}, err
 	}
-	tocItems, err := parseTOCItems(tocNCX.NavPoints, tocResource.Path)
+	navDoc, navPath, err := readNavDocument(pack.Manifest.Items, r, opfPath)
 	if err != nil {
 		return book.Book{}, err
 	}
 
+	var tocItems []book.TOCItem
+	var pageTargets []book.PageTarget
+	var navLists []book.NavList
+	tocResource, ok := parseTOCResource(pack.Manifest.Items, r, opfPath)
+	if ok {
+		tocNCX, err := ncx.Read(tocResource.Contents)
+		if err != nil {
+			return book.Book{}, err
+		}
+		tocItems = parseTOCItems(tocNCX.NavPoints, tocResource.Path)
+		if tocNCX.PageList != nil {
+			pageTargets = parsePageTargets(tocNCX.PageList.PageTargets, tocResource.Path)
+		}
+		navLists = parseNavLists(tocNCX.NavLists, tocResource.Path)
+	} else if toc, ok := navDoc.Find("toc"); ok {
+		// EPUB 3 books may only have the navigation document
+		tocItems, err = parseNavTOCItems(toc.List, navPath)
+		if err != nil {
+			return book.Book{}, err
+		}
+		if pageList, ok := navDoc.Find("page-list"); ok {
+			pageTargets, err = parseNavPageTargets(pageList.List, navPath)
+			if err != nil {
+				return book.Book{}, err
+			}
+		}
+	}
+
 	spineItems, err := parseSpineItems(pack.Spine.ItemRefs)
 	if err != nil {
 		return book.Book{}, err
 	}
 
+	metadata := parseMetadata(pack)
+
+	coverImageID, err := parseCoverImageID(pack.Metadata.Metas, pack.Manifest.Items)
+	if err != nil {
+		return book.Book{}, err
+	}
+
+	guideItems, err := parseGuideItems(pack.Guide.Refs, opfPath)
+	if err != nil {
+		return book.Book{}, err
+	}
+	if len(guideItems) == 0 {
+		// EPUB 3 books may only have landmarks in the navigation document
+		guideItems, err = parseLandmarks(navDoc, navPath)
+		if err != nil {
+			return book.Book{}, err
+		}
+	}
+
+	b := book.Book{
+		Metadata:      metadata,
+		Resources:     resources,
+		SpineItems:    spineItems,
+		CoverImageID:  coverImageID,
+		TOCItems:      tocItems,
+		GuideItems:    guideItems,
+		PageTargets:   pageTargets,
+		NavLists:      navLists,
+		Rendition:     parseRendition(pack.Metadata.Metas, rootFile),
+		MediaOverlays: parseMediaOverlays(pack.Metadata.Metas),
+	}
+	b.Rendition.PageProgression = pack.Spine.PageProgressionDirection
+	if b.Rendition.Layout == "" {
+		// Apple's display options mark fixed layout books before EPUB 3 did
+		if contents, err := readFile(r, displayoptions.Path); err == nil {
+			if options, err := displayoptions.Read(contents); err == nil && options.Option("fixed-layout") == "true" {
+				b.Rendition.Layout = book.LayoutPrePaginated
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// Rendition properties of the package, or of the container's rootfile
+// when the package leaves the layout out
+func parseRendition(metas []opf.Meta, rootFile container.RootFile) book.Rendition {
+	var rendition book.Rendition
+	for _, meta := range metas {
+		if meta.Refines != "" {
+			continue
+		}
+		value := strings.TrimSpace(meta.Value)
+		switch meta.Property {
+		case "rendition:layout":
+			rendition.Layout = value
+		case "rendition:orientation":
+			rendition.Orientation = value
+		case "rendition:spread":
+			rendition.Spread = value
+		}
+	}
+	if rendition.Layout == "" {
+		rendition.Layout = rootFile.Layout
+	}
+	return rendition
+}
+
+func parseMediaOverlays(metas []opf.Meta) book.MediaOverlays {
+	var overlays book.MediaOverlays
+	for _, meta := range metas {
+		value := strings.TrimSpace(meta.Value)
+		switch meta.Property {
+		case "media:duration":
+			if meta.Refines == "" {
+				overlays.Duration = value
+			} else {
+				if overlays.Durations == nil {
+					overlays.Durations = map[string]string{}
+				}
+				overlays.Durations[strings.TrimPrefix(meta.Refines, "#")] = value
+			}
+		case "media:narrator":
+			overlays.Narrators = append(overlays.Narrators, value)
+		case "media:active-class":
+			overlays.ActiveClass = value
+		case "media:playback-active-class":
+			overlays.PlaybackActiveClass = value
+		}
+	}
+	return overlays
+}
+
+// EPUB 2 metas that are modelled as book.Metadata fields
+var modelledMetas = map[string]bool{
+	"cover":                true,
+	"calibre:series":       true,
+	"calibre:series_index": true,
+	"calibre:title_sort":   true,
+}
+
+// Read the metadata of a standalone OPF file, such as a metadata sidecar
+func ReadMetadata(xmlBytes []byte) (book.Metadata, error) {
+	pack, err := opf.Read(xmlBytes)
+	if err != nil {
+		return book.Metadata{}, err
+	}
+	return parseMetadata(pack), nil
+}
+
+func parseMetadata(pack opf.Package) book.Metadata {
+	uniqueIndex := parseUniqueID(pack)
+	uniqueID := ""
+	var identifiers []book.Identifier
+	for i, identifier := range pack.Metadata.Identifiers {
+		if i == uniqueIndex {
+			uniqueID = strings.TrimSpace(identifier.Value)
+			continue
+		}
+		scheme := identifier.Scheme
+		value := strings.TrimSpace(identifier.Value)
+		for _, meta := range refiningMetas(pack.Metadata.Metas, identifier.ID) {
+			if meta.Property == "identifier-type" {
+				scheme = strings.TrimSpace(meta.Value)
+			}
+		}
+		if scheme == "" && strings.HasPrefix(strings.ToLower(value), "urn:isbn:") {
+			scheme = "ISBN"
+			value = value[len("urn:isbn:"):]
+		}
+		identifiers = append(identifiers, book.Identifier{
+			Scheme: scheme,
+			Value:  value,
+		})
+	}
+
+	var creators []book.Creator
+	for _, c := range pack.Metadata.Creators {
+		creator := book.Creator{
+			Name:   strings.TrimSpace(c.Value),
+			FileAs: c.FileAs,
+			Role:   c.Role,
+		}
+		for _, meta := range refiningMetas(pack.Metadata.Metas, c.ID) {
+			switch meta.Property {
+			case "file-as":
+				creator.FileAs = strings.TrimSpace(meta.Value)
+			case "role":
+				creator.Role = strings.TrimSpace(meta.Value)
+			}
+		}
+		creators = append(creators, creator)
+	}
+
 	var dates []book.Date
 	for _, date := range pack.Metadata.Dates {
 		dates = append(dates, book.Date{
@@ -63,117 +273,209 @@ func Read(filepath string) (book.Book, error) {
 			Value: date.Value,
 		})
 	}
+	// EPUB 3 records the other dates as metas instead. dcterms:modified is
+	// when the book was last written, so it's only the modification date
+	// when the book doesn't record its own.
+	modified := ""
+	for _, meta := range pack.Metadata.Metas {
+		if meta.Refines != "" {
+			continue
+		}
+		value := strings.TrimSpace(meta.Value)
+		switch meta.Property {
+		case "dcterms:modified":
+			modified = value
+		case "dcterms:created":
+			dates = append(dates, book.Date{Event: "creation", Value: value})
+		case "dcterms:date":
+			date := book.Date{Event: "publication", Value: value}
+			for _, refining := range refiningMetas(pack.Metadata.Metas, meta.ID) {
+				if refining.Property == dateEventProperty {
+					date.Event = strings.TrimSpace(refining.Value)
+				}
+			}
+			dates = append(dates, date)
+		}
+	}
+	if modified != "" && !hasDateEvent(dates, "modification") {
+		dates = append(dates, book.Date{Event: "modification", Value: modified})
+	}
 
 	var metas []book.Meta
 	for _, meta := range pack.Metadata.Metas {
+		if meta.Name == "" || modelledMetas[meta.Name] {
+			// EPUB 3 metas are either refinements or modelled elsewhere
+			continue
+		}
 		metas = append(metas, book.Meta{
 			Name:    meta.Name,
 			Content: meta.Content,
 		})
 	}
 
-	uniqueID, err := parseUniqueID(pack)
-	if err != nil {
-		return book.Book{}, err
+	series, seriesIndex := parseSeries(pack.Metadata.Metas)
+
+	return book.Metadata{
+		Titles:      parseTitles(pack.Metadata),
+		Identifier:  uniqueID,
+		Identifiers: identifiers,
+		Creators:    creators,
+		Publisher:   pack.Metadata.Publisher,
+		Language:    pack.Metadata.Language,
+		Subjects:    pack.Metadata.Subjects,
+		Description: strings.TrimSpace(pack.Metadata.Description),
+		Series:      series,
+		SeriesIndex: seriesIndex,
+		Rights:      pack.Metadata.Rights,
+		Source:      pack.Metadata.Source,
+		Dates:       dates,
+		Metas:       metas,
 	}
+}
 
-	coverImageID, err := parseCoverImageID(pack.Metadata.Metas, pack.Manifest.Items)
+func readRenditions(r *zip.ReadCloser) ([]container.RootFile, error) {
+	contents, err := readFile(r, container.Path)
 	if err != nil {
-		return book.Book{}, err
+		return nil, err
 	}
-
-	b := book.Book{
-		Title:        pack.Metadata.Title,
-		Identifier:   uniqueID,
-		Creators:     pack.Metadata.Creators,
-		Publisher:    pack.Metadata.Publisher,
-		Language:     pack.Metadata.Language,
-		Subjects:     pack.Metadata.Subjects,
-		Rights:       pack.Metadata.Rights,
-		Source:       pack.Metadata.Source,
-		Dates:        dates,
-		Metas:        metas,
-		Resources:    resources,
-		SpineItems:   spineItems,
-		CoverImageID: coverImageID,
-		TOCItems:     tocItems,
+	ctr, err := container.Read(contents)
+	if err != nil {
+		return nil, err
+	}
+	var renditions []container.RootFile
+	for _, rootFile := range ctr.RootFiles {
+		// Some containers leave out the media type
+		if rootFile.MediaType == opf.MediaType ||
+			(rootFile.MediaType == "" && strings.HasSuffix(strings.ToLower(rootFile.FullPath), ".opf")) {
+			renditions = append(renditions, rootFile)
+		}
+	}
+	if len(renditions) == 0 {
+		return nil, errors.New("container has no package rootfile")
 	}
+	return renditions, nil
+}
 
-	return b, nil
+func chooseRendition(renditions []container.RootFile, rendition Rendition) (container.RootFile, error) {
+	if len(rendition.Properties) == 0 {
+		if rendition.Index < 0 || rendition.Index >= len(renditions) {
+			return container.RootFile{}, fmt.Errorf("can't choose rendition %d, as the book has %d", rendition.Index, len(renditions))
+		}
+		return renditions[rendition.Index], nil
+	}
+	for _, rootFile := range renditions {
+		matches := true
+		for name, value := range rendition.Properties {
+			if rootFile.Property(name) != value {
+				matches = false
+			}
+		}
+		if matches {
+			return rootFile, nil
+		}
+	}
+	return container.RootFile{}, fmt.Errorf("no rendition has properties %v", rendition.Properties)
 }
 
-func absPath(docPath string, relPath string) string {
-	return path.Clean(path.Join(path.Dir(docPath), relPath))
+// The path of a manifest item, which has no fragment
+func manifestPath(opfPath string, href string) string {
+	hrefPath, _ := book.DecodeHref(href)
+	return book.AbsPath(opfPath, hrefPath)
 }
 
-func readFile(r *zip.ReadCloser, path string) []byte {
+func readFile(r *zip.ReadCloser, path string) ([]byte, error) {
 	for _, f := range r.File {
 		if f.Name == path {
 			return readAll(f)
 		}
 	}
-	panic(fmt.Sprintf("could not find in zip %s", path))
+	return nil, fmt.Errorf("could not find in zip %s", path)
 }
 
-func readAll(file *zip.File) []byte {
+func readAll(file *zip.File) ([]byte, error) {
 	fc, err := file.Open()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer fc.Close()
-
-	content, err := ioutil.ReadAll(fc)
-	if err != nil {
-		panic(err)
-	}
-
-	return content
+	return ioutil.ReadAll(fc)
 }
 
-func parseTOCResource(items []opf.ManifestItem, r *zip.ReadCloser, opfPath string) (book.Resource, error) {
+func parseTOCResource(items []opf.ManifestItem, r *zip.ReadCloser, opfPath string) (book.Resource, bool) {
 	for _, item := range items {
-		decodedHref, err := url.QueryUnescape(item.Href)
-		if err != nil {
-			return book.Resource{}, err
+		if item.MediaType != ncx.MediaType {
+			continue
 		}
-		itemPath := absPath(opfPath, decodedHref)
-		if item.MediaType == ncx.MediaType {
-			// This is the toc.ncx file, which we treat separately as metadata
-			return book.Resource{
-				ID:        item.ID,
-				Path:      itemPath,
-				MediaType: item.MediaType,
-				Contents:  readFile(r, itemPath),
-			}, nil
+		// This is the toc.ncx file, which we treat separately as metadata
+		itemPath := manifestPath(opfPath, item.Href)
+		contents, err := readFile(r, itemPath)
+		if err != nil {
+			// Books can do without it when they have a navigation document
+			continue
 		}
+		return book.Resource{
+			ID:        item.ID,
+			Path:      itemPath,
+			MediaType: item.MediaType,
+			Contents:  contents,
+		}, true
 	}
-	return book.Resource{}, errors.New("missing toc.ncx")
+	return book.Resource{}, false
 }
 
-func parseResources(items []opf.ManifestItem, r *zip.ReadCloser, opfPath string) ([]book.Resource, error) {
+func parseResources(items []opf.ManifestItem, itemRefs []opf.SpineItemRef, r *zip.ReadCloser, opfPath string) ([]book.Resource, error) {
+	inSpine := make(map[string]bool)
+	for _, itemRef := range itemRefs {
+		inSpine[itemRef.IDRef] = true
+	}
 	var resources []book.Resource
 	for _, item := range items {
-		decodedHref, err := url.QueryUnescape(item.Href)
-		if err != nil {
-			return nil, err
-		}
-		itemPath := absPath(opfPath, decodedHref)
+		itemPath := manifestPath(opfPath, item.Href)
+		properties := parseProperties(item.Properties)
 		if item.MediaType == ncx.MediaType {
 			// This is the toc.ncx file, which we treat separately as metadata
 		} else if item.MediaType == opf.MediaType {
 			// This is the content.opf file, which we treat separately as metadata
+		} else if hasProperty(item.Properties, nav.Properties) && !inSpine[item.ID] {
+			// This is the nav.xhtml file, which is rebuilt from metadata
 		} else {
+			contents, err := readFile(r, itemPath)
+			if err != nil {
+				return nil, err
+			}
 			resources = append(resources, book.Resource{
-				ID:        item.ID,
-				Path:      itemPath,
-				MediaType: item.MediaType,
-				Contents:  readFile(r, itemPath),
+				ID:           item.ID,
+				Path:         itemPath,
+				MediaType:    item.MediaType,
+				Properties:   properties,
+				MediaOverlay: item.MediaOverlay,
+				Contents:     contents,
 			})
 		}
 	}
 	return resources, nil
 }
 
+// Properties that are modelled elsewhere, or rebuilt on write, are dropped
+func parseProperties(properties string) []string {
+	var kept []string
+	for _, property := range strings.Fields(properties) {
+		if property != nav.Properties && property != "cover-image" {
+			kept = append(kept, property)
+		}
+	}
+	return kept
+}
+
+func hasProperty(properties string, property string) bool {
+	for _, p := range strings.Fields(properties) {
+		if p == property {
+			return true
+		}
+	}
+	return false
+}
+
 func parseSpineItems(itemRefs []opf.SpineItemRef) ([]book.SpineItem, error) {
 	var spineItems []book.SpineItem
 	for _, item := range itemRefs {
@@ -186,47 +488,172 @@ func parseSpineItems(itemRefs []opf.SpineItemRef) ([]book.SpineItem, error) {
 			return nil, fmt.Errorf("unexpected value for linear: %s", item.Linear)
 		}
 		spineItems = append(spineItems, book.SpineItem{
-			ID:     item.IDRef,
-			Linear: linear,
+			ID:         item.IDRef,
+			Linear:     linear,
+			Properties: strings.Fields(item.Properties),
 		})
 	}
 	return spineItems, nil
 }
 
-func parseTOCItems(navPoints []ncx.NavPoint, tocPath string) ([]book.TOCItem, error) {
+// Missing or malformed playOrders are read as 0, as they're renumbered when
+// the book is written
+func parseTOCItems(navPoints []ncx.NavPoint, tocPath string) []book.TOCItem {
 	var tocItems []book.TOCItem
 	for _, np := range navPoints {
-		playOrder, err := strconv.Atoi(np.PlayOrder)
-		if err != nil {
-			return nil, err
-		}
-		children, err := parseTOCItems(np.NavPoints, tocPath)
-		if err != nil {
-			return nil, err
-		}
-		decodedSrc, err := url.QueryUnescape(np.Content.Src)
-		if err != nil {
-			return nil, err
-		}
-		itemPath := absPath(tocPath, decodedSrc)
 		tocItems = append(tocItems, book.TOCItem{
 			ID:        np.ID,
-			PlayOrder: playOrder,
+			PlayOrder: parsePlayOrder(np.PlayOrder),
 			Label:     np.Label,
-			Href:      itemPath,
-			Children:  children,
+			Href:      book.ResolveHref(tocPath, np.Content.Src),
+			Children:  parseTOCItems(np.NavPoints, tocPath),
 		})
 	}
-	return tocItems, nil
+	return tocItems
+}
+
+func parsePlayOrder(playOrder string) int {
+	value, err := strconv.Atoi(strings.TrimSpace(playOrder))
+	if err != nil || value < 0 {
+		return 0
+	}
+	return value
+}
+
+func parsePageTargets(ncxPageTargets []ncx.PageTarget, tocPath string) []book.PageTarget {
+	var pageTargets []book.PageTarget
+	for _, pt := range ncxPageTargets {
+		// The value is optional, and only meaningful for normal pages
+		value, _ := strconv.Atoi(pt.Value)
+		pageTargets = append(pageTargets, book.PageTarget{
+			ID:        pt.ID,
+			PlayOrder: parsePlayOrder(pt.PlayOrder),
+			Type:      pt.Type,
+			Value:     value,
+			Label:     strings.TrimSpace(pt.Label),
+			Href:      book.ResolveHref(tocPath, pt.Content.Src),
+		})
+	}
+	return pageTargets
+}
+
+func parseNavLists(ncxNavLists []ncx.NavList, tocPath string) []book.NavList {
+	var navLists []book.NavList
+	for _, nl := range ncxNavLists {
+		navList := book.NavList{
+			Class: nl.Class,
+			Label: strings.TrimSpace(nl.Label),
+		}
+		for _, nt := range nl.NavTargets {
+			navList.Targets = append(navList.Targets, book.NavTarget{
+				ID:        nt.ID,
+				PlayOrder: parsePlayOrder(nt.PlayOrder),
+				Label:     strings.TrimSpace(nt.Label),
+				Href:      book.ResolveHref(tocPath, nt.Content.Src),
+			})
+		}
+		navLists = append(navLists, navList)
+	}
+	return navLists
+}
+
+func parseTitles(metadata opf.Metadata) []book.Title {
+	var titles []book.Title
+	for _, t := range metadata.Titles {
+		title := book.Title{Value: strings.TrimSpace(t.Value)}
+		for _, meta := range refiningMetas(metadata.Metas, t.ID) {
+			value := strings.TrimSpace(meta.Value)
+			switch meta.Property {
+			case "title-type":
+				title.Type = value
+			case "display-seq":
+				title.DisplaySeq, _ = strconv.Atoi(value)
+			case "file-as":
+				title.FileAs = value
+			}
+		}
+		titles = append(titles, title)
+	}
+	// Calibre records the sort title of EPUB 2 books in its own meta
+	for _, meta := range metadata.Metas {
+		if meta.Name == "calibre:title_sort" && len(titles) > 0 && titles[0].FileAs == "" {
+			titles[0].FileAs = meta.Content
+		}
+	}
+	return titles
+}
+
+// Find EPUB 3 metas that refine the element with the given ID
+func refiningMetas(metas []opf.Meta, ID string) []opf.Meta {
+	var refining []opf.Meta
+	if ID == "" {
+		return refining
+	}
+	for _, meta := range metas {
+		if meta.Refines == "#"+ID {
+			refining = append(refining, meta)
+		}
+	}
+	return refining
+}
+
+// Series are recorded by calibre metas in EPUB 2, or by a collection in EPUB 3
+func parseSeries(metas []opf.Meta) (string, string) {
+	for _, meta := range metas {
+		if meta.Property != "belongs-to-collection" {
+			continue
+		}
+		collectionType := ""
+		position := ""
+		for _, refining := range refiningMetas(metas, meta.ID) {
+			switch refining.Property {
+			case "collection-type":
+				collectionType = strings.TrimSpace(refining.Value)
+			case "group-position":
+				position = strings.TrimSpace(refining.Value)
+			}
+		}
+		if collectionType == "series" || collectionType == "" {
+			return strings.TrimSpace(meta.Value), position
+		}
+	}
+	series := ""
+	seriesIndex := ""
+	for _, meta := range metas {
+		switch meta.Name {
+		case "calibre:series":
+			series = meta.Content
+		case "calibre:series_index":
+			seriesIndex = meta.Content
+		}
+	}
+	return series, seriesIndex
 }
 
-func parseUniqueID(pack opf.Package) (string, error) {
-	for _, identifier := range pack.Metadata.Identifiers {
-		if identifier.ID == pack.UniqueIdentifier {
-			return identifier.Value, nil
+func hasDateEvent(dates []book.Date, event string) bool {
+	for _, date := range dates {
+		if date.Event == event {
+			return true
 		}
 	}
-	return "", errors.New("can't find unique identifier")
+	return false
+}
+
+// Find the index of the unique identifier. When the package doesn't point at
+// one, fall back to the first identifier, or -1 if there are none so that one
+// can be generated later.
+func parseUniqueID(pack opf.Package) int {
+	for i, identifier := range pack.Metadata.Identifiers {
+		if identifier.ID == pack.UniqueIdentifier && strings.TrimSpace(identifier.Value) != "" {
+			return i
+		}
+	}
+	for i, identifier := range pack.Metadata.Identifiers {
+		if strings.TrimSpace(identifier.Value) != "" {
+			return i
+		}
+	}
+	return -1
 }
 
 func parseCoverImageID(metas []opf.Meta, manifestItems []opf.ManifestItem) (string, error) {
@@ -238,6 +665,12 @@ func parseCoverImageID(metas []opf.Meta, manifestItems []opf.ManifestItem) (stri
 		}
 	}
 	if coverMeta == nil {
+		// EPUB 3 marks the cover image in the manifest instead
+		for _, item := range manifestItems {
+			if hasProperty(item.Properties, "cover-image") {
+				return item.ID, nil
+			}
+		}
 		return "", nil
 	}
 	for _, item := range manifestItems {
@@ -247,3 +680,98 @@ func parseCoverImageID(metas []opf.Meta, manifestItems []opf.ManifestItem) (stri
 	}
 	return "", errors.New("can't find cover manifest item")
 }
+
+func parseGuideItems(refs []opf.GuideRef, opfPath string) ([]book.GuideItem, error) {
+	var guideItems []book.GuideItem
+	for _, ref := range refs {
+		guideItems = append(guideItems, book.GuideItem{
+			Type:  ref.Type,
+			Title: ref.Title,
+			Href:  book.ResolveHref(opfPath, ref.Href),
+		})
+	}
+	return guideItems, nil
+}
+
+// The navigation document, if the book has one, and its path
+func readNavDocument(items []opf.ManifestItem, r *zip.ReadCloser, opfPath string) (nav.Nav, string, error) {
+	for _, item := range items {
+		if !hasProperty(item.Properties, nav.Properties) {
+			continue
+		}
+		navPath := manifestPath(opfPath, item.Href)
+		contents, err := readFile(r, navPath)
+		if err != nil {
+			return nav.Nav{}, "", err
+		}
+		navDoc, err := nav.Read(contents)
+		if err != nil {
+			return nav.Nav{}, "", err
+		}
+		return navDoc, navPath, nil
+	}
+	return nav.Nav{}, "", nil
+}
+
+func parseLandmarks(navDoc nav.Nav, navPath string) ([]book.GuideItem, error) {
+	landmarks, ok := navDoc.Find("landmarks")
+	if !ok {
+		return nil, nil
+	}
+	var guideItems []book.GuideItem
+	for _, navItem := range landmarks.List.Items {
+		guideType, ok := guideTypeByLandmark[navItem.Link.Type]
+		if !ok {
+			continue
+		}
+		guideItems = append(guideItems, book.GuideItem{
+			Type:  guideType,
+			Title: navItem.Link.Label,
+			Href:  book.ResolveHref(navPath, navItem.Link.Href),
+		})
+	}
+	return guideItems, nil
+}
+
+func parseNavPageTargets(list nav.List, navPath string) ([]book.PageTarget, error) {
+	var pageTargets []book.PageTarget
+	for _, navItem := range list.Items {
+		value, _ := strconv.Atoi(navItem.Link.Label)
+		pageType := book.PageNormal
+		if value == 0 {
+			pageType = book.PageFront
+		}
+		pageTargets = append(pageTargets, book.PageTarget{
+			Type:  pageType,
+			Value: value,
+			Label: navItem.Link.Label,
+			Href:  book.ResolveHref(navPath, navItem.Link.Href),
+		})
+	}
+	return pageTargets, nil
+}
+
+func parseNavTOCItems(list nav.List, navPath string) ([]book.TOCItem, error) {
+	var tocItems []book.TOCItem
+	for _, navItem := range list.Items {
+		var children []book.TOCItem
+		if navItem.List != nil {
+			var err error
+			children, err = parseNavTOCItems(*navItem.List, navPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if navItem.Link.Href == "" {
+			// Headings without links can't be NCX entries, so lift their children
+			tocItems = append(tocItems, children...)
+			continue
+		}
+		tocItems = append(tocItems, book.TOCItem{
+			Label:    navItem.Link.Label,
+			Href:     book.ResolveHref(navPath, navItem.Link.Href),
+			Children: children,
+		})
+	}
+	return tocItems, nil
+}
@@ -0,0 +1,468 @@
+package epub
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/epub/epubtest"
+	"github.com/asavoy/reprint/epub/opf"
+)
+
+func TestParseTitles(t *testing.T) {
+	metadata := opf.Metadata{
+		Titles: []opf.Title{
+			{ID: "t1", Value: "Go in Practice"},
+			{ID: "t2", Value: " Includes 70 Techniques "},
+			{Value: "Second Edition"},
+		},
+		Metas: []opf.Meta{
+			{Refines: "#t1", Property: "title-type", Value: "main"},
+			{Refines: "#t1", Property: "display-seq", Value: "1"},
+			{Refines: "#t1", Property: "file-as", Value: "Go in Practice"},
+			{Refines: "#t2", Property: "title-type", Value: "subtitle"},
+			{Refines: "#t2", Property: "display-seq", Value: "2"},
+			{Name: "calibre:title_sort", Content: "ignored because of file-as"},
+		},
+	}
+	got := parseTitles(metadata)
+	want := []book.Title{
+		{Value: "Go in Practice", Type: "main", DisplaySeq: 1, FileAs: "Go in Practice"},
+		{Value: "Includes 70 Techniques", Type: "subtitle", DisplaySeq: 2},
+		{Value: "Second Edition"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestParseUniqueID(t *testing.T) {
+	tests := []struct {
+		pack opf.Package
+		want int
+	}{
+		{
+			pack: opf.Package{
+				UniqueIdentifier: "uid",
+				Metadata: opf.Metadata{Identifiers: []opf.Identifier{
+					{Scheme: "ISBN", Value: "9781617291784"},
+					{ID: "uid", Value: "urn:uuid:1234"},
+				}},
+			},
+			want: 1,
+		},
+		{
+			pack: opf.Package{
+				UniqueIdentifier: "missing",
+				Metadata: opf.Metadata{Identifiers: []opf.Identifier{
+					{ID: "empty", Value: " "},
+					{Scheme: "ISBN", Value: "9781617291784"},
+				}},
+			},
+			want: 1,
+		},
+		{
+			pack: opf.Package{UniqueIdentifier: "missing"},
+			want: -1,
+		},
+	}
+	for _, test := range tests {
+		if got := parseUniqueID(test.pack); got != test.want {
+			t.Errorf("got %d != want %d", got, test.want)
+		}
+	}
+}
+
+// Names with spaces, "+", "#" and non-ASCII letters survive writing and
+// reading back
+func TestReadWrittenHrefs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reprint-epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bookPath := filepath.Join(dir, "book.epub")
+	b := book.Book{
+		Metadata: book.Metadata{
+			Titles:     []book.Title{{Value: "A Sample Book"}},
+			Identifier: "urn:uuid:1",
+			Language:   "en",
+		},
+		Resources: []book.Resource{
+			{ID: "c1", Path: "Text/C++ Primer.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><body><h1 id="start">One</h1></body></html>`)},
+			{ID: "c2", Path: "Text/Kapitel #2 – Überblick.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><body><h1 id="two">Two</h1></body></html>`)},
+		},
+		SpineItems: []book.SpineItem{{ID: "c1", Linear: true}, {ID: "c2", Linear: true}},
+		TOCItems: []book.TOCItem{
+			{ID: "navPoint-1", PlayOrder: 1, Label: "One", Href: "Text/C++ Primer.xhtml#start"},
+			{ID: "navPoint-2", PlayOrder: 2, Label: "Two", Href: "Text/Kapitel #2 – Überblick.xhtml#two"},
+		},
+		GuideItems: []book.GuideItem{{Type: book.GuideText, Title: "One", Href: "Text/C++ Primer.xhtml"}},
+	}
+	if err := Write(bookPath, b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotPaths []string
+	for _, r := range got.Resources {
+		gotPaths = append(gotPaths, r.Path)
+	}
+	if diff := cmp.Diff([]string{"Text/C++ Primer.xhtml", "Text/Kapitel #2 – Überblick.xhtml"}, gotPaths); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff(b.TOCItems, got.TOCItems); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff(b.GuideItems, got.GuideItems); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func renditionOPF(title string) string {
+	return `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+	<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+		<dc:identifier id="id">urn:uuid:1</dc:identifier>
+		<dc:title>` + title + `</dc:title>
+		<dc:language>en</dc:language>
+	</metadata>
+	<manifest><item id="c1" href="1.xhtml" media-type="application/xhtml+xml"/></manifest>
+	<spine><itemref idref="c1"/></spine>
+</package>`
+}
+
+func TestReadRendition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reprint-epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	page := `<html xmlns="http://www.w3.org/1999/xhtml"><body><p>Page</p></body></html>`
+	bookPath := epubtest.WriteZip(t, dir, []epubtest.File{
+		{Name: "mimetype", Contents: "application/epub+zip"},
+		{Name: "META-INF/container.xml", Contents: `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:rendition="http://www.idpf.org/2013/rendition">
+	<rootfiles>
+		<rootfile full-path="book.pdf" media-type="application/pdf"/>
+		<rootfile full-path="reflow/content.opf" media-type="application/oebps-package+xml"/>
+		<rootfile full-path="fixed/content.opf" media-type="application/oebps-package+xml" rendition:layout="pre-paginated"/>
+	</rootfiles>
+</container>`},
+		{Name: "reflow/content.opf", Contents: renditionOPF("Reflowable")},
+		{Name: "reflow/1.xhtml", Contents: page},
+		{Name: "fixed/content.opf", Contents: renditionOPF("Fixed")},
+		{Name: "fixed/1.xhtml", Contents: page},
+	})
+
+	tests := []struct {
+		rendition Rendition
+		want      string
+	}{
+		{Rendition{}, "Reflowable"},
+		{Rendition{Index: 1}, "Fixed"},
+		{Rendition{Properties: map[string]string{"layout": "pre-paginated"}}, "Fixed"},
+	}
+	for _, test := range tests {
+		b, err := ReadRendition(bookPath, test.rendition)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := b.MainTitle(); got != test.want {
+			t.Errorf("ReadRendition(%v) read %q, want %q", test.rendition, got, test.want)
+		}
+	}
+
+	for _, rendition := range []Rendition{{Index: 2}, {Properties: map[string]string{"layout": "reflowable"}}} {
+		if _, err := ReadRendition(bookPath, rendition); err == nil {
+			t.Errorf("ReadRendition(%v) didn't fail", rendition)
+		}
+	}
+}
+
+func TestReadWithoutRootFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reprint-epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bookPath := epubtest.WriteZip(t, dir, []epubtest.File{
+		{Name: "mimetype", Contents: "application/epub+zip"},
+		{Name: "META-INF/container.xml", Contents: `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container"><rootfiles/></container>`},
+	})
+	if _, err := Read(bookPath); err == nil {
+		t.Error("Read didn't fail")
+	}
+}
+
+func TestReadFixedLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reprint-epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bookPath := epubtest.WriteZip(t, dir, []epubtest.File{
+		{Name: "mimetype", Contents: "application/epub+zip"},
+		{Name: "META-INF/container.xml", Contents: `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+	<rootfiles><rootfile full-path="content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`},
+		{Name: "META-INF/com.apple.ibooks.display-options.xml", Contents: `<?xml version="1.0" encoding="UTF-8"?>
+<display_options><platform name="*"><option name="fixed-layout">true</option></platform></display_options>`},
+		{Name: "content.opf", Contents: renditionOPF("Picture Book")},
+		{Name: "1.xhtml", Contents: `<html xmlns="http://www.w3.org/1999/xhtml"><body><p>Page</p></body></html>`},
+	})
+	b, err := Read(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(book.Rendition{Layout: book.LayoutPrePaginated}, b.Rendition); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+
+	// Written as rendition properties, which are read back
+	b.Rendition.Spread = "landscape"
+	writtenPath := filepath.Join(dir, "written.epub")
+	if err := Write(writtenPath, b); err != nil {
+		t.Fatal(err)
+	}
+	written, err := Read(writtenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(b.Rendition, written.Rendition); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestReadWrittenDirection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reprint-epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bookPath := filepath.Join(dir, "book.epub")
+	page := []byte(`<html xmlns="http://www.w3.org/1999/xhtml" dir="rtl"><body><p>صفحة</p></body></html>`)
+	b := book.Book{
+		Metadata: book.Metadata{
+			Titles:     []book.Title{{Value: "كتاب"}},
+			Identifier: "urn:uuid:1",
+			Language:   "ar",
+		},
+		Resources: []book.Resource{
+			{ID: "c1", Path: "1.xhtml", MediaType: "application/xhtml+xml", Contents: page},
+			{ID: "c2", Path: "2.xhtml", MediaType: "application/xhtml+xml", Contents: page},
+		},
+		SpineItems: []book.SpineItem{
+			{ID: "c1", Linear: true, Properties: []string{"page-spread-right"}},
+			{ID: "c2", Linear: true, Properties: []string{"page-spread-left", "rendition:layout-pre-paginated"}},
+		},
+		Rendition: book.Rendition{PageProgression: "rtl"},
+	}
+	if err := Write(bookPath, b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(b.SpineItems, got.SpineItems); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff(b.Rendition, got.Rendition); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestReadWrittenMediaOverlays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reprint-epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bookPath := filepath.Join(dir, "book.epub")
+	b := book.Book{
+		Metadata: book.Metadata{
+			Titles:     []book.Title{{Value: "A Read-Along Book"}},
+			Identifier: "urn:uuid:1",
+			Language:   "en",
+		},
+		Resources: []book.Resource{
+			{ID: "p1", Path: "page1.xhtml", MediaType: "application/xhtml+xml", MediaOverlay: "smil1", Contents: []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><body><p id="w1">Once</p></body></html>`)},
+			{ID: "smil1", Path: "page1.smil", MediaType: "application/smil+xml", Contents: []byte(`<smil xmlns="http://www.w3.org/ns/SMIL" version="3.0"><body><par><text src="page1.xhtml#w1"/><audio src="page1.mp3" clipBegin="0s" clipEnd="1.5s"/></par></body></smil>`)},
+			{ID: "mp3", Path: "page1.mp3", MediaType: "audio/mpeg", Contents: []byte("ID3")},
+		},
+		SpineItems: []book.SpineItem{{ID: "p1", Linear: true}},
+		MediaOverlays: book.MediaOverlays{
+			Duration:    "0:00:01.500",
+			Durations:   map[string]string{"smil1": "0:00:01.500"},
+			Narrators:   []string{"Jane Doe"},
+			ActiveClass: "-epub-media-overlay-active",
+		},
+	}
+	if err := Write(bookPath, b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(b.MediaOverlays, got.MediaOverlays); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	if diff := cmp.Diff("smil1", got.Resources[0].MediaOverlay); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestReadWrittenDates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reprint-epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bookPath := filepath.Join(dir, "book.epub")
+	b := book.Book{
+		Metadata: book.Metadata{
+			Titles:     []book.Title{{Value: "A Sample Book"}},
+			Identifier: "urn:uuid:1",
+			Language:   "en",
+			Dates: []book.Date{
+				{Value: "2016-07"},
+				{Event: "creation", Value: "2015"},
+				{Event: "original-publication", Value: "1850"},
+				{Event: "conversion", Value: "2019-06-01"},
+				{Event: "modification", Value: "2019-05-31T14:42:00Z"},
+			},
+		},
+	}
+	// Writing again mustn't add the time of the first write as a date
+	got := b
+	for i := 0; i < 2; i++ {
+		if err := Write(bookPath, got); err != nil {
+			t.Fatal(err)
+		}
+		got, err = Read(bookPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if diff := cmp.Diff(b.Dates, got.Dates); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestReadBadPlayOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reprint-epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bookPath := epubtest.WriteZip(t, dir, []epubtest.File{
+		{Name: "mimetype", Contents: "application/epub+zip"},
+		{Name: "META-INF/container.xml", Contents: `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+	<rootfiles><rootfile full-path="content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`},
+		{Name: "content.opf", Contents: `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="id">
+	<metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Sample</dc:title><dc:identifier id="id">urn:uuid:1</dc:identifier></metadata>
+	<manifest>
+		<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+		<item id="c1" href="1.xhtml" media-type="application/xhtml+xml"/>
+		<item id="c2" href="2.xhtml" media-type="application/xhtml+xml"/>
+	</manifest>
+	<spine toc="ncx"><itemref idref="c1"/><itemref idref="c2"/></spine>
+</package>`},
+		{Name: "toc.ncx", Contents: `<?xml version="1.0"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1"><navMap>
+	<navPoint id="n1" playOrder=""><navLabel><text>One</text></navLabel><content src="1.xhtml"/></navPoint>
+	<navPoint id="n2" playOrder="two"><navLabel><text>Two</text></navLabel><content src="2.xhtml"/></navPoint>
+</navMap>
+<pageList><pageTarget id="p1" type="normal" playOrder="-"><navLabel><text>1</text></navLabel><content src="1.xhtml"/></pageTarget></pageList>
+</ncx>`},
+		{Name: "1.xhtml", Contents: `<html xmlns="http://www.w3.org/1999/xhtml"><body><p>One</p></body></html>`},
+		{Name: "2.xhtml", Contents: `<html xmlns="http://www.w3.org/1999/xhtml"><body><p>Two</p></body></html>`},
+	})
+	b, err := Read(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Renumbered when written
+	writtenPath := filepath.Join(dir, "written.epub")
+	if err := Write(writtenPath, b); err != nil {
+		t.Fatal(err)
+	}
+	written, err := Read(writtenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, item := range written.TOCItems {
+		got = append(got, item.PlayOrder)
+	}
+	for _, pt := range written.PageTargets {
+		got = append(got, pt.PlayOrder)
+	}
+	if diff := cmp.Diff([]int{1, 2, 1}, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestWriteAvoidsResourcePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reprint-epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bookPath := filepath.Join(dir, "book.epub")
+	b := book.Book{
+		Metadata: book.Metadata{
+			Titles:     []book.Title{{Value: "A Sample Book"}},
+			Identifier: "urn:uuid:1",
+			Language:   "en",
+		},
+		Resources: []book.Resource{
+			{ID: "ncx", Path: "nav.xhtml", MediaType: "application/xhtml+xml", Contents: []byte(`<html xmlns="http://www.w3.org/1999/xhtml"><body><p>Navigation</p></body></html>`)},
+			{ID: "notes", Path: "toc.ncx", MediaType: "text/plain", Contents: []byte("Notes")},
+		},
+		SpineItems: []book.SpineItem{{ID: "ncx", Linear: true}},
+	}
+	if err := Write(bookPath, b); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	names := map[string]bool{}
+	for _, f := range r.File {
+		if names[f.Name] {
+			t.Errorf("duplicate zip entry %s", f.Name)
+		}
+		names[f.Name] = true
+	}
+
+	got, err := Read(bookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(b.Resources, got.Resources); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+	// An empty TOC is written with an entry for the first page
+	want := []book.TOCItem{{ID: "navPoint-1", PlayOrder: 1, Label: "A Sample Book", Href: "nav.xhtml"}}
+	if diff := cmp.Diff(want, got.TOCItems); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
@@ -5,20 +5,31 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/asavoy/reprint/book"
 	"github.com/asavoy/reprint/epub/container"
+	"github.com/asavoy/reprint/epub/displayoptions"
+	"github.com/asavoy/reprint/epub/nav"
 	"github.com/asavoy/reprint/epub/ncx"
 	"github.com/asavoy/reprint/epub/opf"
 )
 
-// Write an EPUBv2 format book
+// Write an EPUBv3 format book, which includes the EPUBv2 NCX and guide for
+// older reading systems
 func Write(filepath string, b book.Book) error {
+	_, err := WriteWithWarnings(filepath, b)
+	return err
+}
+
+// Write a book as Write does, returning warnings for TOC entries with broken
+// links
+func WriteWithWarnings(filepath string, b book.Book) ([]string, error) {
 	zipFile, err := os.Create(filepath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer zipFile.Close()
 
@@ -33,69 +44,102 @@ func Write(filepath string, b book.Book) error {
 	opfPath := "content.opf"
 	containerContents, err := container.Write(buildContainer(opfPath))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Build the EPUB data
+	// Build the EPUB data. Every package needs a unique identifier, so books
+	// without one get one derived from their contents.
+	if b.Identifier == "" {
+		b.Identifier = book.GenerateUUID(b)
+	}
 	resources := b.Resources
+	if len(b.TOCItems) == 0 {
+		b.TOCItems = buildFallbackTOCItems(b)
+	}
 
-	// Build toc.ncx
-	ncxContents, err := ncx.Write(buildNCX(b))
+	// Build toc.ncx, avoiding the IDs and paths of the book's own resources
+	toc := buildNCX(b)
+	warnings := renumberNCX(&toc, b)
+	ncxContents, err := ncx.Write(toc)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	ncxID := book.UniqueResourceID(resources, "ncx")
 	resources = append(resources, book.Resource{
-		ID:        "ncx",
-		Path:      "toc.ncx",
+		ID:        ncxID,
+		Path:      book.UniqueResourcePath(resources, "toc.ncx"),
 		MediaType: "application/x-dtbncx+xml",
 		Contents:  ncxContents,
 	})
 
+	// Build nav.xhtml, which EPUB 3 requires in addition to toc.ncx
+	navContents, err := nav.Write(buildNav(b))
+	if err != nil {
+		return nil, err
+	}
+	resources = append(resources, book.Resource{
+		ID:         book.UniqueResourceID(resources, "nav"),
+		Path:       book.UniqueResourcePath(resources, "nav.xhtml"),
+		MediaType:  "application/xhtml+xml",
+		Properties: []string{nav.Properties},
+		Contents:   navContents,
+	})
+
 	// Build content.opf
-	var metas []opf.Meta
+	titles, metas := buildTitles(b.Metadata)
+	identifierName := "PrimaryIdentifier"
+	identifiers, identifierMetas := buildIdentifiers(identifierName, b.Identifier, b.Identifiers)
+	metas = append(metas, identifierMetas...)
+	creators, creatorMetas := buildCreators(b.Creators)
+	metas = append(metas, creatorMetas...)
+	dates, dateMetas := buildDates(b.Dates, time.Now())
+	metas = append(metas, dateMetas...)
 	if b.CoverImageID != "" {
+		// For EPUB 2 reading systems, in addition to the cover-image property
 		metas = append(metas, opf.Meta{
 			Name:    "cover",
 			Content: b.CoverImageID,
 		})
 	}
-	var dates []opf.Date
-	for _, bookDate := range b.Dates {
-		dates = append(dates, opf.Date{
-			Event: bookDate.Event,
-			Value: bookDate.Value,
-		})
+	metas = append(metas, buildSeriesMetas(b.Series, b.SeriesIndex)...)
+	metas = append(metas, buildRenditionMetas(b.Rendition)...)
+	metas = append(metas, buildMediaOverlayMetas(b.MediaOverlays, b.Resources)...)
+	prefix := ""
+	if usesReprintPrefix(metas) {
+		prefix = reprintPrefix
 	}
-	identifierName := "PrimaryIdentifier"
 	opfContents, err := opf.Write(opf.Package{
-		Version:          "2.0",
+		Version:          packageVersion,
+		Prefix:           prefix,
 		UniqueIdentifier: identifierName,
 		Metadata: opf.Metadata{
-			Title: b.Title,
-			Identifiers: []opf.Identifier{
-				{ID: identifierName, Value: b.Identifier},
-			},
-			Creators:  b.Creators,
-			Publisher: b.Publisher,
-			Language:  b.Language,
-			Subjects:  b.Subjects,
-			Rights:    b.Rights,
-			Source:    b.Source,
-			Metas:     metas,
-			Dates:     dates,
+			Titles:      titles,
+			Identifiers: identifiers,
+			Creators:    creators,
+			Publisher:   b.Publisher,
+			Language:    b.Language,
+			Subjects:    b.Subjects,
+			Description: b.Description,
+			Rights:      b.Rights,
+			Source:      b.Source,
+			Metas:       metas,
+			Dates:       dates,
 		},
 		Manifest: opf.Manifest{
-			Items: buildManifestItems(resources),
+			Items: buildManifestItems(resources, b.CoverImageID),
 		},
 		Spine: opf.Spine{
-			Toc:      "ncx",
-			ItemRefs: buildSpineItemRefs(b.SpineItems),
+			Toc:                      ncxID,
+			PageProgressionDirection: b.Rendition.PageProgression,
+			ItemRefs:                 buildSpineItemRefs(b.SpineItems),
+		},
+		// For EPUB 2 reading systems, in addition to the landmarks nav
+		Guide: opf.Guide{
+			Refs: buildGuideRefs(b.GuideItems),
 		},
-		// Don't bother with guide because Apple Books doesn't support it
-		Guide: opf.Guide{},
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Write mimetype file
@@ -105,51 +149,80 @@ func Write(filepath string, b book.Book) error {
 		Modified: time.Now(),
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	_, err = fileWriter.Write(mimetypeContents)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Write container.xml
 	fileWriter, err = zipWriter.Create(container.Path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	_, err = fileWriter.Write(containerContents)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Write Apple's display options, for versions of Books that predate
+	// rendition properties
+	if b.FixedLayout() {
+		displayOptionsContents, err := displayoptions.Write(displayoptions.DisplayOptions{
+			Platforms: []displayoptions.Platform{{
+				Name:    "*",
+				Options: []displayoptions.Option{{Name: "fixed-layout", Value: "true"}},
+			}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		fileWriter, err = zipWriter.Create(displayoptions.Path)
+		if err != nil {
+			return nil, err
+		}
+		_, err = fileWriter.Write(displayOptionsContents)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Write content.opf
 	fileWriter, err = zipWriter.Create(opfPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	_, err = fileWriter.Write(opfContents)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Write all resources, including toc.ncx
 	for _, resource := range resources {
 		fileWriter, err = zipWriter.Create(resource.Path)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		_, err = fileWriter.Write(resource.Contents)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	err = zipWriter.Close()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return warnings, nil
+}
+
+// Hrefs in the OPF, NCX and navigation document, which are all at the root
+// of the book
+func encodeHref(href string) string {
+	hrefPath, fragment := book.SplitHref(href)
+	return book.EncodeHref(hrefPath, fragment)
 }
 
 func buildContainer(opfPath string) container.Container {
@@ -172,18 +245,45 @@ func buildNCX(b book.Book) ncx.NCX {
 			maxDepth = depth
 		}
 	}
-	return ncx.NCX{
+	toc := ncx.NCX{
 		Version: "2005-1",
 		Metas: []ncx.Meta{
 			{Name: "dtb:uid", Content: b.Identifier},
 			{Name: "dtb:depth", Content: fmt.Sprintf("%d", maxDepth)},
-			{Name: "dtb:totalPageCount", Content: "0"},
-			{Name: "dtb:maxPageNumber", Content: "0"},
+			{Name: "dtb:totalPageCount", Content: fmt.Sprintf("%d", len(b.PageTargets))},
+			{Name: "dtb:maxPageNumber", Content: fmt.Sprintf("%d", getMaxPageNumber(b.PageTargets))},
 		},
-		Title:     b.Title,
-		Author:    strings.Join(b.Creators, ", "),
+		Title:     b.MainTitle(),
+		Author:    strings.Join(b.CreatorNames(), ", "),
 		NavPoints: buildNavPoints(b.TOCItems),
+		NavLists:  buildNCXNavLists(b.NavLists),
 	}
+	if len(b.PageTargets) > 0 {
+		toc.PageList = &ncx.PageList{
+			Label:       "Pages",
+			PageTargets: buildPageTargets(b.PageTargets),
+		}
+	}
+	return toc
+}
+
+// The highest normal page number, falling back to the printed labels when
+// page values weren't given
+func getMaxPageNumber(pageTargets []book.PageTarget) int {
+	maxPageNumber := 0
+	for _, pt := range pageTargets {
+		if pt.Type != book.PageNormal {
+			continue
+		}
+		value := pt.Value
+		if value == 0 {
+			value, _ = strconv.Atoi(pt.Label)
+		}
+		if value > maxPageNumber {
+			maxPageNumber = value
+		}
+	}
+	return maxPageNumber
 }
 
 func getMaxDepth(tocItem book.TOCItem) int {
@@ -197,18 +297,224 @@ func getMaxDepth(tocItem book.TOCItem) int {
 	return maxDepth
 }
 
-func buildManifestItems(resources []book.Resource) []opf.ManifestItem {
+// Titles are written with EPUB 3 refinements, with the main title first
+// because EPUB 2 reading systems only display the first
+func buildTitles(m book.Metadata) ([]opf.Title, []opf.Meta) {
+	var ordered []book.Title
+	mainIndex := m.MainTitleIndex()
+	if mainIndex >= 0 {
+		ordered = append(ordered, m.Titles[mainIndex])
+	}
+	for i, t := range m.Titles {
+		if i != mainIndex {
+			ordered = append(ordered, t)
+		}
+	}
+
+	var titles []opf.Title
+	var metas []opf.Meta
+	for i, t := range ordered {
+		ID := fmt.Sprintf("title%d", i+1)
+		titles = append(titles, opf.Title{ID: ID, Value: t.Value})
+		if t.Type != "" {
+			metas = append(metas, opf.Meta{Refines: "#" + ID, Property: "title-type", Value: t.Type})
+		}
+		if t.DisplaySeq != 0 {
+			metas = append(metas, opf.Meta{Refines: "#" + ID, Property: "display-seq", Value: fmt.Sprintf("%d", t.DisplaySeq)})
+		}
+		if t.FileAs != "" {
+			metas = append(metas, opf.Meta{Refines: "#" + ID, Property: "file-as", Value: t.FileAs})
+		}
+	}
+	return titles, metas
+}
+
+// Series are written both as calibre metas for EPUB 2 reading systems, and
+// as an EPUB 3 collection
+func buildSeriesMetas(series string, seriesIndex string) []opf.Meta {
+	if series == "" {
+		return nil
+	}
+	metas := []opf.Meta{
+		{Name: "calibre:series", Content: series},
+		{ID: "series", Property: "belongs-to-collection", Value: series},
+		{Refines: "#series", Property: "collection-type", Value: "series"},
+	}
+	if seriesIndex != "" {
+		metas = append(metas,
+			opf.Meta{Name: "calibre:series_index", Content: seriesIndex},
+			opf.Meta{Refines: "#series", Property: "group-position", Value: seriesIndex},
+		)
+	}
+	return metas
+}
+
+func buildRenditionMetas(rendition book.Rendition) []opf.Meta {
+	var metas []opf.Meta
+	for _, property := range []struct {
+		name  string
+		value string
+	}{
+		{"rendition:layout", rendition.Layout},
+		{"rendition:orientation", rendition.Orientation},
+		{"rendition:spread", rendition.Spread},
+	} {
+		if property.value != "" {
+			metas = append(metas, opf.Meta{Property: property.name, Value: property.value})
+		}
+	}
+	return metas
+}
+
+// Durations are written in the order of the resources they refine, leaving
+// out those of missing resources
+func buildMediaOverlayMetas(overlays book.MediaOverlays, resources []book.Resource) []opf.Meta {
+	var metas []opf.Meta
+	if overlays.Duration != "" {
+		metas = append(metas, opf.Meta{Property: "media:duration", Value: overlays.Duration})
+	}
+	for _, r := range resources {
+		if duration, ok := overlays.Durations[r.ID]; ok {
+			metas = append(metas, opf.Meta{Property: "media:duration", Refines: "#" + r.ID, Value: duration})
+		}
+	}
+	for _, narrator := range overlays.Narrators {
+		metas = append(metas, opf.Meta{Property: "media:narrator", Value: narrator})
+	}
+	if overlays.ActiveClass != "" {
+		metas = append(metas, opf.Meta{Property: "media:active-class", Value: overlays.ActiveClass})
+	}
+	if overlays.PlaybackActiveClass != "" {
+		metas = append(metas, opf.Meta{Property: "media:playback-active-class", Value: overlays.PlaybackActiveClass})
+	}
+	return metas
+}
+
+func buildManifestItems(resources []book.Resource, coverImageID string) []opf.ManifestItem {
 	var manifestItems []opf.ManifestItem
 	for _, resource := range resources {
+		properties := resource.Properties
+		if resource.ID == coverImageID {
+			properties = append([]string{"cover-image"}, properties...)
+		}
 		manifestItems = append(manifestItems, opf.ManifestItem{
-			ID:        resource.ID,
-			Href:      resource.Path,
-			MediaType: resource.MediaType,
+			ID:           resource.ID,
+			Href:         book.EncodeHref(resource.Path, ""),
+			MediaType:    resource.MediaType,
+			Properties:   strings.Join(properties, " "),
+			MediaOverlay: resource.MediaOverlay,
 		})
 	}
 	return manifestItems
 }
 
+func buildGuideRefs(guideItems []book.GuideItem) []opf.GuideRef {
+	var refs []opf.GuideRef
+	for _, guideItem := range guideItems {
+		refs = append(refs, opf.GuideRef{
+			Type:  guideItem.Type,
+			Title: guideItem.Title,
+			Href:  encodeHref(guideItem.Href),
+		})
+	}
+	return refs
+}
+
+func buildNav(b book.Book) nav.Nav {
+	n := nav.Nav{
+		EPUBNS: nav.EPUBNS,
+		Title:  b.MainTitle(),
+		Navs: []nav.NavElement{
+			{Type: "toc", List: buildNavList(b.TOCItems)},
+		},
+	}
+	var landmarks []nav.Item
+	for _, guideItem := range b.GuideItems {
+		landmark, ok := landmarkByGuideType[guideItem.Type]
+		if !ok {
+			continue
+		}
+		label := guideItem.Title
+		if label == "" {
+			label = guideItem.Type
+		}
+		landmarks = append(landmarks, nav.Item{
+			Link: nav.Link{Type: landmark, Href: encodeHref(guideItem.Href), Label: label},
+		})
+	}
+	if len(b.PageTargets) > 0 {
+		var pages []nav.Item
+		for _, pt := range b.PageTargets {
+			pages = append(pages, nav.Item{
+				Link: nav.Link{Href: encodeHref(pt.Href), Label: pt.Label},
+			})
+		}
+		n.Navs = append(n.Navs, nav.NavElement{
+			Type:   "page-list",
+			Hidden: "hidden",
+			List:   nav.List{Items: pages},
+		})
+	}
+	for _, navList := range b.NavLists {
+		// Only lists with a known kind, such as "loi" or "lot", can be typed
+		if navList.Class == "" {
+			continue
+		}
+		var targets []nav.Item
+		for _, target := range navList.Targets {
+			targets = append(targets, nav.Item{
+				Link: nav.Link{Href: encodeHref(target.Href), Label: target.Label},
+			})
+		}
+		n.Navs = append(n.Navs, nav.NavElement{
+			Type:    navList.Class,
+			Heading: navList.Label,
+			List:    nav.List{Items: targets},
+		})
+	}
+	if len(landmarks) > 0 {
+		n.Navs = append(n.Navs, nav.NavElement{
+			Type:   "landmarks",
+			Hidden: "hidden",
+			List:   nav.List{Items: landmarks},
+		})
+	}
+	return n
+}
+
+// Both the NCX and navigation document need at least one entry, so a book
+// without a TOC gets one for its first page
+func buildFallbackTOCItems(b book.Book) []book.TOCItem {
+	for _, spineItem := range b.SpineItems {
+		for _, r := range b.Resources {
+			if r.ID != spineItem.ID {
+				continue
+			}
+			label := b.MainTitle()
+			if label == "" {
+				label = "Start"
+			}
+			return []book.TOCItem{{Label: label, Href: r.Path}}
+		}
+	}
+	return nil
+}
+
+func buildNavList(tocItems []book.TOCItem) nav.List {
+	var list nav.List
+	for _, tocItem := range tocItems {
+		item := nav.Item{
+			Link: nav.Link{Href: encodeHref(tocItem.Href), Label: tocItem.Label},
+		}
+		if len(tocItem.Children) > 0 {
+			children := buildNavList(tocItem.Children)
+			item.List = &children
+		}
+		list.Items = append(list.Items, item)
+	}
+	return list
+}
+
 func buildSpineItemRefs(spineItems []book.SpineItem) []opf.SpineItemRef {
 	var spineItemRefs []opf.SpineItemRef
 	for _, spineItem := range spineItems {
@@ -219,8 +525,9 @@ func buildSpineItemRefs(spineItems []book.SpineItem) []opf.SpineItemRef {
 			linear = "no"
 		}
 		spineItemRefs = append(spineItemRefs, opf.SpineItemRef{
-			IDRef:  spineItem.ID,
-			Linear: linear,
+			IDRef:      spineItem.ID,
+			Linear:     linear,
+			Properties: strings.Join(spineItem.Properties, " "),
 		})
 	}
 	return spineItemRefs
@@ -234,9 +541,52 @@ func buildNavPoints(tocItems []book.TOCItem) []ncx.NavPoint {
 			ID:        item.ID,
 			PlayOrder: fmt.Sprintf("%d", item.PlayOrder),
 			Label:     item.Label,
-			Content:   ncx.Content{Src: item.Href},
+			Content:   ncx.Content{Src: encodeHref(item.Href)},
 			NavPoints: children,
 		})
 	}
 	return navPoints
 }
+
+func buildPageTargets(pageTargets []book.PageTarget) []ncx.PageTarget {
+	var ncxPageTargets []ncx.PageTarget
+	for _, pt := range pageTargets {
+		var value string
+		if pt.Value > 0 {
+			value = fmt.Sprintf("%d", pt.Value)
+		}
+		pageType := pt.Type
+		if pageType == "" {
+			pageType = book.PageNormal
+		}
+		ncxPageTargets = append(ncxPageTargets, ncx.PageTarget{
+			ID:        pt.ID,
+			Type:      pageType,
+			Value:     value,
+			PlayOrder: fmt.Sprintf("%d", pt.PlayOrder),
+			Label:     pt.Label,
+			Content:   ncx.Content{Src: encodeHref(pt.Href)},
+		})
+	}
+	return ncxPageTargets
+}
+
+func buildNCXNavLists(navLists []book.NavList) []ncx.NavList {
+	var ncxNavLists []ncx.NavList
+	for _, navList := range navLists {
+		ncxNavList := ncx.NavList{
+			Class: navList.Class,
+			Label: navList.Label,
+		}
+		for _, target := range navList.Targets {
+			ncxNavList.NavTargets = append(ncxNavList.NavTargets, ncx.NavTarget{
+				ID:        target.ID,
+				PlayOrder: fmt.Sprintf("%d", target.PlayOrder),
+				Label:     target.Label,
+				Content:   ncx.Content{Src: encodeHref(target.Href)},
+			})
+		}
+		ncxNavLists = append(ncxNavLists, ncxNavList)
+	}
+	return ncxNavLists
+}
@@ -0,0 +1,136 @@
+package epub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/asavoy/reprint/book"
+	"github.com/asavoy/reprint/epub/nav"
+	"github.com/asavoy/reprint/epub/opf"
+)
+
+func TestBuildTitles(t *testing.T) {
+	titles := []book.Title{
+		{Value: "Includes 70 Techniques", Type: "subtitle", DisplaySeq: 2},
+		{Value: "Go in Practice", Type: "main", DisplaySeq: 1, FileAs: "Go in Practice"},
+	}
+	gotTitles, gotMetas := buildTitles(book.Metadata{Titles: titles})
+	wantTitles := []opf.Title{
+		{ID: "title1", Value: "Go in Practice"},
+		{ID: "title2", Value: "Includes 70 Techniques"},
+	}
+	wantMetas := []opf.Meta{
+		{Refines: "#title1", Property: "title-type", Value: "main"},
+		{Refines: "#title1", Property: "display-seq", Value: "1"},
+		{Refines: "#title1", Property: "file-as", Value: "Go in Practice"},
+		{Refines: "#title2", Property: "title-type", Value: "subtitle"},
+		{Refines: "#title2", Property: "display-seq", Value: "2"},
+	}
+	if diff := cmp.Diff(wantTitles, gotTitles); diff != "" {
+		t.Error("titles got != want:\n", diff)
+	}
+	if diff := cmp.Diff(wantMetas, gotMetas); diff != "" {
+		t.Error("metas got != want:\n", diff)
+	}
+}
+
+func TestBuildTitlesWithoutMain(t *testing.T) {
+	titles := []book.Title{
+		{Value: "Includes 70 Techniques", Type: "subtitle"},
+		{Value: "Go in Practice: Includes 70 Techniques", Type: "expanded"},
+	}
+	gotTitles, gotMetas := buildTitles(book.Metadata{Titles: titles})
+	wantTitles := []opf.Title{
+		{ID: "title1", Value: "Go in Practice: Includes 70 Techniques"},
+		{ID: "title2", Value: "Includes 70 Techniques"},
+	}
+	wantMetas := []opf.Meta{
+		{Refines: "#title1", Property: "title-type", Value: "expanded"},
+		{Refines: "#title2", Property: "title-type", Value: "subtitle"},
+	}
+	if diff := cmp.Diff(wantTitles, gotTitles); diff != "" {
+		t.Error("titles got != want:\n", diff)
+	}
+	if diff := cmp.Diff(wantMetas, gotMetas); diff != "" {
+		t.Error("metas got != want:\n", diff)
+	}
+}
+
+func TestBuildNav(t *testing.T) {
+	b := book.Book{
+		Metadata: book.Metadata{Titles: []book.Title{{Value: "A Sample Book"}}},
+		TOCItems: []book.TOCItem{
+			{Label: "Chapter 1", Href: "text/1.xhtml"},
+		},
+		GuideItems: []book.GuideItem{
+			{Type: book.GuideCover, Title: "Cover", Href: "text/cover.xhtml"},
+			{Type: book.GuideText, Href: "text/1.xhtml#start"},
+			{Type: "other.ads", Title: "Ads", Href: "text/ads.xhtml"},
+		},
+	}
+	got := buildNav(b)
+	want := nav.Nav{
+		EPUBNS: nav.EPUBNS,
+		Title:  "A Sample Book",
+		Navs: []nav.NavElement{
+			{
+				Type: "toc",
+				List: nav.List{Items: []nav.Item{
+					{Link: nav.Link{Href: "text/1.xhtml", Label: "Chapter 1"}},
+				}},
+			},
+			{
+				Type:   "landmarks",
+				Hidden: "hidden",
+				List: nav.List{Items: []nav.Item{
+					{Link: nav.Link{Type: "cover", Href: "text/cover.xhtml", Label: "Cover"}},
+					{Link: nav.Link{Type: "bodymatter", Href: "text/1.xhtml#start", Label: "text"}},
+				}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("got != want:\n", diff)
+	}
+}
+
+func TestBuildDates(t *testing.T) {
+	gotDates, gotMetas := buildDates([]book.Date{
+		{Event: "creation", Value: "2015"},
+		{Event: "publication", Value: "2016-07"},
+		{Event: "modification", Value: "May 2019"},
+		{Event: "conversion", Value: "2019-06-01"},
+		{Event: "publication", Value: "2017"},
+	}, time.Date(2020, 1, 2, 13, 4, 5, 0, time.FixedZone("AEDT", 11*60*60)))
+	wantDates := []opf.Date{{Value: "2016-07"}}
+	wantMetas := []opf.Meta{
+		{Property: "dcterms:created", Value: "2015"},
+		{ID: "date3", Property: "dcterms:date", Value: "May 2019"},
+		{Refines: "#date3", Property: "reprint:event", Value: "modification"},
+		{ID: "date4", Property: "dcterms:date", Value: "2019-06-01"},
+		{Refines: "#date4", Property: "reprint:event", Value: "conversion"},
+		{ID: "date5", Property: "dcterms:date", Value: "2017"},
+		{Refines: "#date5", Property: "reprint:event", Value: "publication"},
+		{Property: "dcterms:modified", Value: "2020-01-02T02:04:05Z"},
+	}
+	if diff := cmp.Diff(wantDates, gotDates); diff != "" {
+		t.Error("dates got != want:\n", diff)
+	}
+	if diff := cmp.Diff(wantMetas, gotMetas); diff != "" {
+		t.Error("metas got != want:\n", diff)
+	}
+}
+
+func TestGetMaxPageNumber(t *testing.T) {
+	pageTargets := []book.PageTarget{
+		{Type: book.PageFront, Label: "xii"},
+		{Type: book.PageNormal, Value: 1, Label: "1"},
+		{Type: book.PageNormal, Label: "312"},
+		{Type: book.PageSpecial, Label: "999"},
+	}
+	if got := getMaxPageNumber(pageTargets); got != 312 {
+		t.Errorf("got %d, want 312", got)
+	}
+}
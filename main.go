@@ -8,10 +8,42 @@ import (
 	"github.com/asavoy/reprint/cmd"
 )
 
+var commands = map[string]func(args []string) error{
+	"meta":   cmd.Meta,
+	"export": cmd.Export,
+	"import": cmd.Import,
+	"toc":    cmd.TOC,
+	"check":  cmd.Check,
+	"links":  cmd.Links,
+	"prune":  cmd.Prune,
+	"layout": cmd.Layout,
+	"spine":  cmd.Spine,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			err := command(os.Args[2:])
+			if err != nil {
+				fmt.Println("error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	switch len(os.Args) {
 	case 1:
 		fmt.Printf("usage: %s source.epub [fixed.epub]\n", os.Args[0])
+		fmt.Printf("       %s meta [flags] book.epub\n", os.Args[0])
+		fmt.Printf("       %s export [flags] book.epub [book.json]\n", os.Args[0])
+		fmt.Printf("       %s import [flags] book.epub book.json\n", os.Args[0])
+		fmt.Printf("       %s toc [flags] book.epub\n", os.Args[0])
+		fmt.Printf("       %s check [flags] book.epub...\n", os.Args[0])
+		fmt.Printf("       %s links [flags] book.epub\n", os.Args[0])
+		fmt.Printf("       %s prune [flags] book.epub\n", os.Args[0])
+		fmt.Printf("       %s layout [flags] book.epub\n", os.Args[0])
+		fmt.Printf("       %s spine [flags] book.epub\n", os.Args[0])
 	case 2:
 		inPath := os.Args[1]
 		outDir := path.Dir(inPath)
@@ -19,6 +51,7 @@ func main() {
 		outPath := path.Join(outDir, outFilename)
 		if inPath == outPath {
 			fmt.Println("error: inPath and outPath are the same!")
+			return
 		}
 		err := cmd.Run(inPath, outPath)
 		if err != nil {
@@ -27,7 +60,10 @@ func main() {
 	case 3:
 		inPath := os.Args[1]
 		outPath := os.Args[2]
-		fmt.Println("error: inPath and outPath are the same!")
+		if inPath == outPath {
+			fmt.Println("error: inPath and outPath are the same!")
+			return
+		}
 		err := cmd.Run(inPath, outPath)
 		if err != nil {
 			fmt.Println("error:", err)